@@ -2,32 +2,72 @@ package proc
 
 import "sync"
 
-type ListenerManager struct {
-	lock      sync.Mutex
-	wg        sync.WaitGroup
-	listeners []func()
-}
+type (
+	ListenerManager struct {
+		lock      sync.Mutex
+		wg        sync.WaitGroup
+		listeners []*listener
+	}
+
+	listener struct {
+		fn func()
+	}
+)
 
 func (m *ListenerManager) add(fn func()) (waitForCalled func()) {
+	m.push(fn)
+
+	return func() {
+		m.wg.Wait()
+	}
+}
+
+// addRemovable 添加监听器，返回移除监听器的函数，监听器已执行或已移除时移除不做处理
+func (m *ListenerManager) addRemovable(fn func()) (remove func()) {
+	l := m.push(fn)
+
+	return func() {
+		m.remove(l)
+	}
+}
+
+func (m *ListenerManager) push(fn func()) *listener {
 	m.wg.Add(1)
 
+	l := &listener{
+		fn: func() {
+			defer m.wg.Done()
+			fn()
+		},
+	}
 	m.lock.Lock()
-	m.listeners = append(m.listeners, func() {
-		defer m.wg.Done()
-		fn()
-	})
+	m.listeners = append(m.listeners, l)
 	m.lock.Unlock()
 
-	return func() {
-		m.wg.Wait()
+	return l
+}
+
+func (m *ListenerManager) remove(l *listener) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for i, item := range m.listeners {
+		if item == l {
+			m.listeners = append(m.listeners[:i], m.listeners[i+1:]...)
+			m.wg.Done()
+			return
+		}
 	}
 }
 
+// notify 依次执行所有监听器，执行时不持有锁，以便监听器中移除自身
 func (m *ListenerManager) notify() {
 	m.lock.Lock()
-	defer m.lock.Unlock()
+	listeners := m.listeners
+	m.listeners = nil
+	m.lock.Unlock()
 
-	for _, listener := range m.listeners {
-		listener()
+	for _, l := range listeners {
+		l.fn()
 	}
 }
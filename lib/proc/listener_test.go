@@ -0,0 +1,32 @@
+package proc
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestListenerManager_Remove(t *testing.T) {
+	var m ListenerManager
+	var called []int
+	wait := m.add(func() {
+		called = append(called, 1)
+	})
+	remove := m.addRemovable(func() {
+		called = append(called, 2)
+	})
+	var removeSelf func()
+	removeSelf = m.addRemovable(func() {
+		called = append(called, 3)
+		// 监听器执行时移除自身不会死锁
+		removeSelf()
+	})
+
+	remove()
+	remove()
+	assert.Equal(t, 2, len(m.listeners))
+
+	m.notify()
+	wait()
+	assert.Equal(t, []int{1, 3}, called)
+	assert.Equal(t, 0, len(m.listeners))
+}
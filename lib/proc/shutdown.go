@@ -18,6 +18,12 @@ func AddShutdownListener(listener func()) (waitForCalled func()) {
 	return shutdownListeners.add(listener)
 }
 
+// AddRemovableShutdownListener 添加一个可移除的程序关闭监听器，返回移除该监听器的函数，
+// 生命周期短于程序的对象关闭时应移除监听器，以免一直被引用而无法回收
+func AddRemovableShutdownListener(listener func()) (remove func()) {
+	return shutdownListeners.addRemovable(listener)
+}
+
 // gracefulStop 平滑停止程序（为关闭类监听器的执行留有时间）
 func gracefulStop(signals chan os.Signal) {
 	signal.Stop(signals)
@@ -31,6 +37,6 @@ func gracefulStop(signals chan os.Signal) {
 	time.Sleep(waitTime)
 
 	// 关闭程序
-	logx.Info("已等待 %v 秒，即将强制杀死该进程", waitTime)
+	logx.Infof("已等待 %v，即将强制杀死该进程", waitTime)
 	syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
 }
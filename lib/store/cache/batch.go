@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"errors"
+	"github.com/z-sdk/goa/lib/logx"
+	"reflect"
+)
+
+var errNotSlicePtr = errors.New("批量读取的目标须为切片指针")
+
+// batchCache 批量读写原始缓存值，node 和 cluster 均已实现
+type batchCache interface {
+	// mget 批量读取，未缓存的键对应空串
+	mget(keys []string) ([]string, error)
+	// mset 批量缓存 values，并将 notFound 中的键缓存为空记录占位符
+	mset(values map[string]string, notFound []string)
+}
+
+// getMany 批量读取缓存写入 dest，dest 为切片指针，元素与 keys 一一对应，未命中的元素为零值
+func getMany(c batchCache, cd codec, keys []string, dest interface{}) error {
+	slice, err := makeDestSlice(dest, len(keys))
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	values, err := c.mget(keys)
+	if err != nil {
+		return err
+	}
+
+	for i, value := range values {
+		if len(value) == 0 || value == notFoundPlaceholder {
+			continue
+		}
+
+		if err = unmarshalElem(cd, slice.Index(i), value); err != nil {
+			logx.Errorf("解封缓存失败，键：%s，值：%s，错误：%v", keys[i], value, err)
+		}
+	}
+
+	return nil
+}
+
+// takeMany 批量读取缓存写入 dest，未命中的键一次交由 queryMissing 查库并缓存，
+// queryMissing 未返回的键视为不存在，缓存为空记录占位符，对应元素为零值
+func takeMany(c batchCache, cd codec, stat *Stat, keys []string, dest interface{},
+	queryMissing func(missingKeys []string) (map[string]interface{}, error)) error {
+	slice, err := makeDestSlice(dest, len(keys))
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	values, err := c.mget(keys)
+	if err != nil {
+		// 直接返回错误而不是继续查库，以防高并发拖垮数据库
+		return err
+	}
+
+	// 同一个键可能出现多次，只查一次
+	var missing []string
+	indexes := make(map[string][]int)
+	for i, value := range values {
+		if value == notFoundPlaceholder {
+			continue
+		}
+		if len(value) > 0 {
+			if err = unmarshalElem(cd, slice.Index(i), value); err == nil {
+				continue
+			}
+
+			// 无效的缓存值当作未命中，重新查库覆盖
+			slice.Index(i).Set(reflect.Zero(slice.Type().Elem()))
+		}
+
+		if _, ok := indexes[keys[i]]; !ok {
+			missing = append(missing, keys[i])
+		}
+		indexes[keys[i]] = append(indexes[keys[i]], i)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	loaded, err := queryMissing(missing)
+	if err != nil {
+		stat.IncrDbFails()
+		return err
+	}
+
+	cached := make(map[string]string, len(loaded))
+	var notFound []string
+	for _, key := range missing {
+		value, ok := loaded[key]
+		if !ok || value == nil {
+			notFound = append(notFound, key)
+			continue
+		}
+
+		data, err := cd.marshal(value)
+		if err != nil {
+			return err
+		}
+		for _, i := range indexes[key] {
+			if err = unmarshalElem(cd, slice.Index(i), string(data)); err != nil {
+				return err
+			}
+		}
+		cached[key] = string(data)
+	}
+	c.mset(cached, notFound)
+
+	return nil
+}
+
+// makeDestSlice 将 dest 指向的切片重置为 n 个零值元素
+func makeDestSlice(dest interface{}, n int) (reflect.Value, error) {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return reflect.Value{}, errNotSlicePtr
+	}
+
+	slice := reflect.MakeSlice(v.Elem().Type(), n, n)
+	v.Elem().Set(slice)
+	return slice, nil
+}
+
+func unmarshalElem(cd codec, elem reflect.Value, value string) error {
+	return cd.unmarshal([]byte(value), elem.Addr().Interface())
+}
@@ -0,0 +1,203 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"github.com/alicebob/miniredis"
+	"github.com/stretchr/testify/assert"
+	"github.com/z-sdk/goa/lib/store/redis"
+	"github.com/z-sdk/goa/lib/syncx"
+	"testing"
+	"time"
+)
+
+type batchUser struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestNode_TakeMany(t *testing.T) {
+	s, err := miniredis.Run()
+	assert.Nil(t, err)
+	defer s.Close()
+
+	errNotFound := errors.New("not found")
+	stat := &Stat{name: "batch"}
+	c := NewCacheNode(redis.NewRedis(s.Addr(), redis.StandaloneMode), syncx.NewSharedCalls(), stat, errNotFound)
+	assert.Nil(t, c.Set("user#1", batchUser{Id: 1, Name: "kevin"}))
+	assert.Nil(t, s.Set("user#4", notFoundPlaceholder))
+
+	var queried [][]string
+	query := func(missingKeys []string) (map[string]interface{}, error) {
+		queried = append(queried, missingKeys)
+		return map[string]interface{}{
+			"user#2": batchUser{Id: 2, Name: "jack"},
+		}, nil
+	}
+
+	var users []*batchUser
+	keys := []string{"user#1", "user#2", "user#3", "user#4", "user#2"}
+	assert.Nil(t, c.TakeMany(keys, &users, query))
+	assert.Equal(t, []*batchUser{{Id: 1, Name: "kevin"}, {Id: 2, Name: "jack"}, nil, nil,
+		{Id: 2, Name: "jack"}}, users)
+	assert.Equal(t, [][]string{{"user#2", "user#3"}}, queried)
+
+	// 查到的值已缓存，不存在的键缓存为占位符
+	val, err := s.Get("user#3")
+	assert.Nil(t, err)
+	assert.Equal(t, notFoundPlaceholder, val)
+	assert.True(t, s.TTL("user#2") > 0)
+
+	users = nil
+	assert.Nil(t, c.TakeMany(keys, &users, query))
+	assert.Equal(t, 1, len(queried))
+	assert.Equal(t, &batchUser{Id: 2, Name: "jack"}, users[1])
+
+	var names []batchUser
+	assert.Nil(t, c.GetMany([]string{"user#5", "user#1"}, &names))
+	assert.Equal(t, []batchUser{{}, {Id: 1, Name: "kevin"}}, names)
+
+	assert.Equal(t, errNotSlicePtr, c.GetMany(keys, names))
+
+	// 查库失败时返回错误
+	dbErr := errors.New("db down")
+	assert.Equal(t, dbErr, c.TakeMany([]string{"user#6"}, &users,
+		func(missingKeys []string) (map[string]interface{}, error) {
+			return nil, dbErr
+		}))
+	assert.Equal(t, uint64(1), stat.DbFails)
+}
+
+func TestCluster_TakeMany(t *testing.T) {
+	s1, err := miniredis.Run()
+	assert.Nil(t, err)
+	defer s1.Close()
+	s2, err := miniredis.Run()
+	assert.Nil(t, err)
+	defer s2.Close()
+
+	confs := ClusterConf{
+		{Conf: redis.Conf{Host: s1.Addr(), Mode: redis.StandaloneMode}, Weight: 100},
+		{Conf: redis.Conf{Host: s2.Addr(), Mode: redis.StandaloneMode}, Weight: 100},
+	}
+	c := NewCacheCluster(confs, syncx.NewSharedCalls(), &Stat{name: "batch"}, errors.New("not found"))
+
+	const total = 100
+	keys := make([]string, total)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key#%d", i)
+		if i%2 == 0 {
+			assert.Nil(t, c.Set(keys[i], i))
+		}
+	}
+
+	var queries int
+	var values []int
+	assert.Nil(t, c.TakeMany(keys, &values, func(missingKeys []string) (map[string]interface{}, error) {
+		queries++
+		assert.Equal(t, total/2, len(missingKeys))
+		loaded := make(map[string]interface{})
+		for _, key := range missingKeys {
+			var i int
+			_, err := fmt.Sscanf(key, "key#%d", &i)
+			assert.Nil(t, err)
+			loaded[key] = i
+		}
+		return loaded, nil
+	}))
+	assert.Equal(t, 1, queries)
+	for i, value := range values {
+		assert.Equal(t, i, value)
+	}
+
+	// 所有键已分布缓存到两个节点
+	assert.True(t, len(s1.Keys()) > 0)
+	assert.True(t, len(s2.Keys()) > 0)
+	assert.Equal(t, total, len(s1.Keys())+len(s2.Keys()))
+
+	values = nil
+	assert.Nil(t, c.GetMany(keys, &values))
+	for i, value := range values {
+		assert.Equal(t, i, value)
+	}
+}
+
+// uncomparableSerializer 含 map 字段的自定义序列化器，其值不可比较
+type uncomparableSerializer struct {
+	jsonSerializer
+	options map[string]bool
+}
+
+func TestCluster_UncomparableSerializer(t *testing.T) {
+	s1, err := miniredis.Run()
+	assert.Nil(t, err)
+	defer s1.Close()
+	s2, err := miniredis.Run()
+	assert.Nil(t, err)
+	defer s2.Close()
+
+	confs := ClusterConf{
+		{Conf: redis.Conf{Host: s1.Addr(), Mode: redis.StandaloneMode}, Weight: 100},
+		{Conf: redis.Conf{Host: s2.Addr(), Mode: redis.StandaloneMode}, Weight: 100},
+	}
+	c := NewCacheCluster(confs, syncx.NewSharedCalls(), &Stat{name: "batch"}, errors.New("not found"),
+		WithSerializer(uncomparableSerializer{options: map[string]bool{}}))
+
+	keys := make([]string, 20)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key#%d", i)
+	}
+
+	// 按节点分组时不以节点值作为 map 的键，否则会因不可比较而 panic
+	var values []int
+	assert.Nil(t, c.TakeMany(keys, &values, func(missingKeys []string) (map[string]interface{}, error) {
+		loaded := make(map[string]interface{})
+		for _, key := range missingKeys {
+			loaded[key] = len(key)
+		}
+		return loaded, nil
+	}))
+	assert.Equal(t, len(keys), len(s1.Keys())+len(s2.Keys()))
+
+	values = nil
+	assert.Nil(t, c.GetMany(keys, &values))
+	assert.Equal(t, len(keys), len(values))
+	assert.Equal(t, len(keys[0]), values[0])
+
+	assert.Nil(t, c.Del(keys...))
+	assert.Equal(t, 0, len(s1.Keys())+len(s2.Keys()))
+}
+
+func TestLocalCache_TakeMany(t *testing.T) {
+	next := &mockedNode{vals: make(map[string][]byte), errNotFound: errors.New("not found")}
+	local := newLocalCache(next, &Stat{name: "batch"}, newOptions(WithLocalCache(10, time.Minute)))
+
+	var queried [][]string
+	query := func(missingKeys []string) (map[string]interface{}, error) {
+		queried = append(queried, missingKeys)
+		loaded := make(map[string]interface{})
+		for _, key := range missingKeys {
+			if key != "c" {
+				loaded[key] = key
+			}
+		}
+		return loaded, nil
+	}
+
+	var values []string
+	assert.Nil(t, local.TakeMany([]string{"a", "b", "c"}, &values, query))
+	assert.Equal(t, []string{"a", "b", ""}, values)
+	assert.Equal(t, 2, local.lru.Len())
+
+	// 本地命中的键不再读下层缓存
+	delete(next.vals, "a")
+	values = nil
+	assert.Nil(t, local.TakeMany([]string{"c", "a", "d"}, &values, query))
+	assert.Equal(t, []string{"", "a", "d"}, values)
+	assert.Equal(t, [][]string{{"a", "b", "c"}, {"d"}}, queried)
+	assert.Equal(t, uint64(1), local.stat.LocalHit)
+
+	values = nil
+	assert.Nil(t, local.GetMany([]string{"b", "x"}, &values))
+	assert.Equal(t, []string{"b", ""}, values)
+}
@@ -0,0 +1,454 @@
+package cache
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"time"
+)
+
+// 二进制编码中每个值前的类型标记，基础类型直接使用 reflect.Kind 的取值
+const (
+	binaryNil    = byte(reflect.Invalid)
+	binaryBytes  = 100 // []byte
+	binaryTime   = 101 // time.Time
+	binaryCustom = 102 // 实现了 encoding.BinaryMarshaler 的类型
+)
+
+var (
+	timeType              = reflect.TypeOf(time.Time{})
+	binaryMarshalerType   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+
+	// 解码到 interface{} 时，各类型标记对应的具体类型
+	binaryKindTypes = map[byte]reflect.Type{
+		byte(reflect.Bool):    reflect.TypeOf(false),
+		byte(reflect.Int):     reflect.TypeOf(0),
+		byte(reflect.Int8):    reflect.TypeOf(int8(0)),
+		byte(reflect.Int16):   reflect.TypeOf(int16(0)),
+		byte(reflect.Int32):   reflect.TypeOf(int32(0)),
+		byte(reflect.Int64):   reflect.TypeOf(int64(0)),
+		byte(reflect.Uint):    reflect.TypeOf(uint(0)),
+		byte(reflect.Uint8):   reflect.TypeOf(uint8(0)),
+		byte(reflect.Uint16):  reflect.TypeOf(uint16(0)),
+		byte(reflect.Uint32):  reflect.TypeOf(uint32(0)),
+		byte(reflect.Uint64):  reflect.TypeOf(uint64(0)),
+		byte(reflect.Float32): reflect.TypeOf(float32(0)),
+		byte(reflect.Float64): reflect.TypeOf(float64(0)),
+		byte(reflect.String):  reflect.TypeOf(""),
+		binaryBytes:           reflect.TypeOf([]byte(nil)),
+		binaryTime:            timeType,
+	}
+)
+
+type (
+	// binarySerializer 紧凑二进制序列化器：每个值以一个字节的类型标记开头，整数为变长编码，
+	// 结构体按导出字段的顺序编码且不含字段名，结构体定义变化后旧值解码失败，按未命中重新加载
+	binarySerializer struct{}
+
+	binaryDecoder struct {
+		*bytes.Reader
+	}
+)
+
+func (s binarySerializer) Format() byte {
+	return FormatBinary
+}
+
+func (s binarySerializer) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeBinary(&buf, reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (s binarySerializer) Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("二进制解码的目标须为非空指针，得到 %T", v)
+	}
+
+	d := binaryDecoder{Reader: bytes.NewReader(data)}
+	if err := d.decode(rv.Elem()); err != nil {
+		return err
+	}
+	if d.Len() > 0 {
+		return fmt.Errorf("二进制解码后剩余 %d 字节", d.Len())
+	}
+
+	return nil
+}
+
+func encodeBinary(buf *bytes.Buffer, v reflect.Value) error {
+	if !v.IsValid() {
+		return buf.WriteByte(binaryNil)
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return buf.WriteByte(binaryNil)
+		}
+		return encodeBinary(buf, v.Elem())
+	}
+
+	if v.Type() == timeType {
+		data, err := v.Interface().(time.Time).MarshalBinary()
+		if err != nil {
+			return err
+		}
+		writeBinaryBytes(buf, binaryTime, data)
+		return nil
+	}
+	if marshaler, ok := binaryMarshalerOf(v); ok {
+		data, err := marshaler.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		writeBinaryBytes(buf, binaryCustom, data)
+		return nil
+	}
+
+	kind := byte(v.Kind())
+	switch v.Kind() {
+	case reflect.Bool:
+		buf.WriteByte(kind)
+		if v.Bool() {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		buf.WriteByte(kind)
+		writeVarint(buf, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		buf.WriteByte(kind)
+		writeUvarint(buf, v.Uint())
+	case reflect.Float32:
+		var scratch [4]byte
+		binary.LittleEndian.PutUint32(scratch[:], math.Float32bits(float32(v.Float())))
+		buf.WriteByte(kind)
+		buf.Write(scratch[:])
+	case reflect.Float64:
+		var scratch [8]byte
+		binary.LittleEndian.PutUint64(scratch[:], math.Float64bits(v.Float()))
+		buf.WriteByte(kind)
+		buf.Write(scratch[:])
+	case reflect.String:
+		writeBinaryBytes(buf, kind, []byte(v.String()))
+	case reflect.Slice:
+		if v.IsNil() {
+			return buf.WriteByte(binaryNil)
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			writeBinaryBytes(buf, binaryBytes, v.Bytes())
+			return nil
+		}
+		fallthrough
+	case reflect.Array:
+		buf.WriteByte(kind)
+		writeUvarint(buf, uint64(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			if err := encodeBinary(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			return buf.WriteByte(binaryNil)
+		}
+		buf.WriteByte(kind)
+		writeUvarint(buf, uint64(v.Len()))
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := encodeBinary(buf, iter.Key()); err != nil {
+				return err
+			}
+			if err := encodeBinary(buf, iter.Value()); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		fields := exportedFields(v.Type())
+		buf.WriteByte(kind)
+		writeUvarint(buf, uint64(len(fields)))
+		for _, i := range fields {
+			if err := encodeBinary(buf, v.Field(i)); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("二进制编码不支持的类型 %s", v.Type())
+	}
+
+	return nil
+}
+
+func (d binaryDecoder) decode(v reflect.Value) error {
+	tag, err := d.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	return d.decodeTagged(v, tag)
+}
+
+func (d binaryDecoder) decodeTagged(v reflect.Value, tag byte) error {
+	if tag == binaryNil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decodeTagged(v.Elem(), tag)
+	case reflect.Interface:
+		typ, ok := binaryKindTypes[tag]
+		if !ok || v.NumMethod() > 0 {
+			return fmt.Errorf("无法将类型标记 %d 解码到 %s", tag, v.Type())
+		}
+		value := reflect.New(typ).Elem()
+		if err := d.decodeTagged(value, tag); err != nil {
+			return err
+		}
+		v.Set(value)
+		return nil
+	}
+
+	switch tag {
+	case binaryTime, binaryCustom:
+		data, err := d.readBytes()
+		if err != nil {
+			return err
+		}
+		if !reflect.PtrTo(v.Type()).Implements(binaryUnmarshalerType) {
+			return d.mismatch(v, tag)
+		}
+		return v.Addr().Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(data)
+	case binaryBytes:
+		data, err := d.readBytes()
+		if err != nil {
+			return err
+		}
+		if v.Kind() != reflect.Slice || v.Type().Elem().Kind() != reflect.Uint8 {
+			return d.mismatch(v, tag)
+		}
+		v.SetBytes(data)
+		return nil
+	}
+
+	switch reflect.Kind(tag) {
+	case reflect.Bool:
+		b, err := d.ReadByte()
+		if err != nil {
+			return err
+		}
+		if v.Kind() != reflect.Bool {
+			return d.mismatch(v, tag)
+		}
+		v.SetBool(b != 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := binary.ReadVarint(d)
+		if err != nil {
+			return err
+		}
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if v.OverflowInt(n) {
+				return d.mismatch(v, tag)
+			}
+			v.SetInt(n)
+		default:
+			return d.mismatch(v, tag)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := binary.ReadUvarint(d)
+		if err != nil {
+			return err
+		}
+		switch v.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if v.OverflowUint(n) {
+				return d.mismatch(v, tag)
+			}
+			v.SetUint(n)
+		default:
+			return d.mismatch(v, tag)
+		}
+	case reflect.Float32, reflect.Float64:
+		size := 8
+		if reflect.Kind(tag) == reflect.Float32 {
+			size = 4
+		}
+		data, err := d.readN(size)
+		if err != nil {
+			return err
+		}
+		if v.Kind() != reflect.Float32 && v.Kind() != reflect.Float64 {
+			return d.mismatch(v, tag)
+		}
+		if size == 4 {
+			v.SetFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(data))))
+		} else {
+			v.SetFloat(math.Float64frombits(binary.LittleEndian.Uint64(data)))
+		}
+	case reflect.String:
+		data, err := d.readBytes()
+		if err != nil {
+			return err
+		}
+		if v.Kind() != reflect.String {
+			return d.mismatch(v, tag)
+		}
+		v.SetString(string(data))
+	case reflect.Slice, reflect.Array:
+		n, err := d.readLen()
+		if err != nil {
+			return err
+		}
+		switch v.Kind() {
+		case reflect.Slice:
+			v.Set(reflect.MakeSlice(v.Type(), n, n))
+		case reflect.Array:
+			if v.Len() != n {
+				return d.mismatch(v, tag)
+			}
+		default:
+			return d.mismatch(v, tag)
+		}
+		for i := 0; i < n; i++ {
+			if err = d.decode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		n, err := d.readLen()
+		if err != nil {
+			return err
+		}
+		if v.Kind() != reflect.Map {
+			return d.mismatch(v, tag)
+		}
+		v.Set(reflect.MakeMapWithSize(v.Type(), n))
+		for i := 0; i < n; i++ {
+			key := reflect.New(v.Type().Key()).Elem()
+			if err = d.decode(key); err != nil {
+				return err
+			}
+			value := reflect.New(v.Type().Elem()).Elem()
+			if err = d.decode(value); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, value)
+		}
+	case reflect.Struct:
+		n, err := d.readLen()
+		if err != nil {
+			return err
+		}
+		if v.Kind() != reflect.Struct {
+			return d.mismatch(v, tag)
+		}
+		fields := exportedFields(v.Type())
+		if len(fields) != n {
+			return d.mismatch(v, tag)
+		}
+		for _, i := range fields {
+			if err = d.decode(v.Field(i)); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("无效的二进制类型标记 %d", tag)
+	}
+
+	return nil
+}
+
+func (d binaryDecoder) readLen() (int, error) {
+	n, err := binary.ReadUvarint(d)
+	if err != nil {
+		return 0, err
+	}
+	// 长度不会超过剩余的字节数，防止无效数据导致超大分配
+	if n > uint64(d.Len()) {
+		return 0, errInvalidFormat
+	}
+
+	return int(n), nil
+}
+
+func (d binaryDecoder) readBytes() ([]byte, error) {
+	n, err := d.readLen()
+	if err != nil {
+		return nil, err
+	}
+
+	return d.readN(n)
+}
+
+func (d binaryDecoder) readN(n int) ([]byte, error) {
+	if n > d.Len() {
+		return nil, errInvalidFormat
+	}
+
+	data := make([]byte, n)
+	if _, err := io.ReadFull(d, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (d binaryDecoder) mismatch(v reflect.Value, tag byte) error {
+	return fmt.Errorf("二进制类型标记 %d 与目标类型 %s 不符", tag, v.Type())
+}
+
+// binaryMarshalerOf 值或其指针实现了 encoding.BinaryMarshaler 时返回之
+func binaryMarshalerOf(v reflect.Value) (encoding.BinaryMarshaler, bool) {
+	if v.Type().Implements(binaryMarshalerType) {
+		return v.Interface().(encoding.BinaryMarshaler), true
+	}
+	if v.CanAddr() && reflect.PtrTo(v.Type()).Implements(binaryMarshalerType) {
+		return v.Addr().Interface().(encoding.BinaryMarshaler), true
+	}
+
+	return nil, false
+}
+
+// exportedFields 结构体导出字段的下标
+func exportedFields(t reflect.Type) []int {
+	var fields []int
+	for i := 0; i < t.NumField(); i++ {
+		if len(t.Field(i).PkgPath) == 0 {
+			fields = append(fields, i)
+		}
+	}
+
+	return fields
+}
+
+func writeBinaryBytes(buf *bytes.Buffer, tag byte, data []byte) {
+	buf.WriteByte(tag)
+	writeUvarint(buf, uint64(len(data)))
+	buf.Write(data)
+}
+
+func writeVarint(buf *bytes.Buffer, n int64) {
+	var scratch [binary.MaxVarintLen64]byte
+	buf.Write(scratch[:binary.PutVarint(scratch[:], n)])
+}
+
+func writeUvarint(buf *bytes.Buffer, n uint64) {
+	var scratch [binary.MaxVarintLen64]byte
+	buf.Write(scratch[:binary.PutUvarint(scratch[:], n)])
+}
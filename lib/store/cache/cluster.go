@@ -17,10 +17,16 @@ type (
 		SetEx(key string, val interface{}, expires time.Duration) error
 		Take(dest interface{}, key string, queryFn func(interface{}) error) error
 		TakeEx(dest interface{}, key string, queryFn func(interface{}, time.Duration) error) error
+		// GetMany 批量读取，dest 为切片指针，元素与 keys 一一对应，未命中的元素为零值，建议使用指针元素
+		GetMany(keys []string, dest interface{}) error
+		// TakeMany 批量读取，未命中的键一次交由 queryMissing 查库并缓存，queryMissing 未返回的键视为不存在
+		TakeMany(keys []string, dest interface{}, queryMissing func(missingKeys []string) (map[string]interface{}, error)) error
 	}
 
 	cluster struct {
 		dispatcher  *hash.ConsistentHash
+		stat        *Stat
+		codec       codec
 		errNotFound error
 	}
 )
@@ -34,17 +40,20 @@ func NewCacheCluster(confs ClusterConf, barrier syncx.SharedCalls, stat *Stat, e
 		return NewCacheNode(confs[0].NewRedis(), barrier, stat, errNotFound, opts...)
 	}
 
-	// 添加一批 redis 缓存节点
+	// 添加一批 redis 缓存节点，本地缓存加在整个集群之前
+	o := newOptions(opts...)
 	dispatcher := hash.NewConsistentHash()
 	for _, conf := range confs {
-		node := NewCacheNode(conf.NewRedis(), barrier, stat, errNotFound, opts...)
+		node := newCacheNode(conf.NewRedis(), barrier, stat, errNotFound, o)
 		dispatcher.AddWithWeight(node, conf.Weight)
 	}
 
-	return cluster{
+	return withLocalCache(cluster{
 		dispatcher:  dispatcher,
+		stat:        stat,
+		codec:       newCodec(o),
 		errNotFound: errNotFound,
-	}
+	}, confs[0].NewRedis(), stat, o)
 }
 
 func (c cluster) Del(keys ...string) error {
@@ -59,8 +68,13 @@ func (c cluster) Del(keys ...string) error {
 		}
 		return node.(Cache).Del(key)
 	default:
+		type group struct {
+			node Cache
+			keys []string
+		}
+
 		var es errorx.Errors
-		nodes := make(map[interface{}][]string)
+		groups := make(map[string]*group)
 		for _, key := range keys {
 			node, ok := c.dispatcher.Get(key)
 			if !ok {
@@ -68,10 +82,16 @@ func (c cluster) Del(keys ...string) error {
 				continue
 			}
 
-			nodes[node] = append(nodes[node], key)
+			addr := nodeAddr(node)
+			g, ok := groups[addr]
+			if !ok {
+				g = &group{node: node.(Cache)}
+				groups[addr] = g
+			}
+			g.keys = append(g.keys, key)
 		}
-		for node, keys := range nodes {
-			if err := node.(Cache).Del(keys...); err != nil {
+		for _, g := range groups {
+			if err := g.node.Del(g.keys...); err != nil {
 				es.Add(err)
 			}
 		}
@@ -124,3 +144,103 @@ func (c cluster) TakeEx(dest interface{}, key string, queryFn func(newVal interf
 
 	return node.(Cache).TakeEx(dest, key, queryFn)
 }
+
+func (c cluster) GetMany(keys []string, dest interface{}) error {
+	return getMany(c, c.codec, keys, dest)
+}
+
+func (c cluster) TakeMany(keys []string, dest interface{},
+	queryMissing func(missingKeys []string) (map[string]interface{}, error)) error {
+	return takeMany(c, c.codec, c.stat, keys, dest, queryMissing)
+}
+
+// mget 按节点分组批量读取，每个节点一次 MGET
+func (c cluster) mget(keys []string) ([]string, error) {
+	type group struct {
+		node    batchCache
+		indexes []int
+	}
+
+	values := make([]string, len(keys))
+	groups := make(map[string]*group)
+	for i, key := range keys {
+		node, ok := c.dispatcher.Get(key)
+		if !ok {
+			continue
+		}
+
+		addr := nodeAddr(node)
+		g, ok := groups[addr]
+		if !ok {
+			g = &group{node: node.(batchCache)}
+			groups[addr] = g
+		}
+		g.indexes = append(g.indexes, i)
+	}
+
+	for _, g := range groups {
+		nodeKeys := make([]string, len(g.indexes))
+		for i, index := range g.indexes {
+			nodeKeys[i] = keys[index]
+		}
+
+		nodeValues, err := g.node.mget(nodeKeys)
+		if err != nil {
+			return nil, err
+		}
+		for i, index := range g.indexes {
+			values[index] = nodeValues[i]
+		}
+	}
+
+	return values, nil
+}
+
+// mset 按节点分组批量缓存
+func (c cluster) mset(values map[string]string, notFound []string) {
+	type batch struct {
+		node     batchCache
+		values   map[string]string
+		notFound []string
+	}
+
+	batches := make(map[string]*batch)
+	getBatch := func(key string) *batch {
+		node, ok := c.dispatcher.Get(key)
+		if !ok {
+			return nil
+		}
+
+		addr := nodeAddr(node)
+		b, ok := batches[addr]
+		if !ok {
+			b = &batch{node: node.(batchCache), values: make(map[string]string)}
+			batches[addr] = b
+		}
+		return b
+	}
+
+	for key, value := range values {
+		if b := getBatch(key); b != nil {
+			b.values[key] = value
+		}
+	}
+	for _, key := range notFound {
+		if b := getBatch(key); b != nil {
+			b.notFound = append(b.notFound, key)
+		}
+	}
+
+	for _, b := range batches {
+		b.node.mset(b.values, b.notFound)
+	}
+}
+
+// nodeAddr 返回节点地址，用于按节点分组。节点含可配置的序列化器等字段，可能不可比较，不能直接作为 map 的键
+func nodeAddr(node interface{}) string {
+	if s, ok := node.(fmt.Stringer); ok {
+		return s.String()
+	}
+
+	return fmt.Sprintf("%p", node)
+}
@@ -71,6 +71,32 @@ func (n *mockedNode) TakeEx(dest interface{}, key string, queryFn func(newVal in
 	})
 }
 
+func (n *mockedNode) GetMany(keys []string, dest interface{}) error {
+	return getMany(n, newCodec(newOptions()), keys, dest)
+}
+
+func (n *mockedNode) TakeMany(keys []string, dest interface{},
+	queryMissing func(missingKeys []string) (map[string]interface{}, error)) error {
+	return takeMany(n, newCodec(newOptions()), &Stat{name: "mock"}, keys, dest, queryMissing)
+}
+
+func (n *mockedNode) mget(keys []string) ([]string, error) {
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		values[i] = string(n.vals[key])
+	}
+	return values, nil
+}
+
+func (n *mockedNode) mset(values map[string]string, notFound []string) {
+	for key, value := range values {
+		n.vals[key] = []byte(value)
+	}
+	for _, key := range notFound {
+		n.vals[key] = []byte(notFoundPlaceholder)
+	}
+}
+
 func TestCluster_SetDel(t *testing.T) {
 	const total = 1000
 	//r1 := miniredis.NewMiniRedis()
@@ -0,0 +1,191 @@
+package cache
+
+import (
+	"encoding/json"
+	"github.com/z-sdk/goa/lib/lang"
+	"github.com/z-sdk/goa/lib/logx"
+	"github.com/z-sdk/goa/lib/store/redis"
+	"github.com/z-sdk/goa/lib/syncx"
+	"github.com/z-sdk/goa/lib/threading"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	invalidationPingInterval  = 30 * time.Second // 订阅连接的探活间隔
+	invalidationRetryInterval = time.Second      // 订阅失败后的重试间隔
+)
+
+// invalidator 经 redis 发布订阅在进程间同步本地缓存的失效：
+// 本进程改删缓存时发布键，所有进程收到后淘汰各自的本地缓存。
+// 订阅中断期间的消息会丢失，因此断开时停用并清空本地缓存，重新订阅成功后再启用
+type invalidator struct {
+	redis   *redis.Redis
+	channel string
+	local   *localCache
+	ready   *syncx.AtomicBool
+
+	lock      sync.Mutex
+	pubsub    *redis.PubSub // 当前的订阅，关闭时取消订阅并释放连接
+	done      chan lang.PlaceholderType
+	exited    chan lang.PlaceholderType
+	closeOnce sync.Once
+}
+
+func newInvalidator(r *redis.Redis, channel string, local *localCache) *invalidator {
+	return &invalidator{
+		redis:   r,
+		channel: channel,
+		local:   local,
+		ready:   syncx.NewAtomicBool(),
+		done:    make(chan lang.PlaceholderType),
+		exited:  make(chan lang.PlaceholderType),
+	}
+}
+
+// start 后台订阅失效频道
+func (i *invalidator) start() {
+	threading.GoSafe(func() {
+		defer close(i.exited)
+		i.run()
+	})
+}
+
+// close 取消订阅并等待后台订阅退出，之后停用并清空本地缓存，可重复调用
+func (i *invalidator) close() {
+	i.closeOnce.Do(func() {
+		close(i.done)
+
+		i.lock.Lock()
+		pubsub := i.pubsub
+		i.pubsub = nil
+		i.lock.Unlock()
+		if pubsub != nil {
+			if err := pubsub.Unsubscribe(i.channel); err != nil {
+				logx.Errorf("取消订阅缓存失效频道失败，频道：%s，错误：%v", i.channel, err)
+			}
+			// 关闭连接以结束阻塞中的接收
+			pubsub.Close()
+		}
+
+		<-i.exited
+		i.ready.Set(false)
+		i.local.flush()
+	})
+}
+
+// publish 广播失效的键，失败时仅记录日志，其他进程的本地缓存到期后自然失效
+func (i *invalidator) publish(keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(keys)
+	if err == nil {
+		_, err = i.redis.Publish(i.channel, string(data))
+	}
+	if err != nil {
+		logx.Errorf("发布缓存失效消息失败，频道：%s，keys: %q, 错误: %v", i.channel, formatKeys(keys), err)
+	}
+}
+
+func (i *invalidator) run() {
+	for {
+		pubsub, err := i.redis.Subscribe(i.channel)
+		if err != nil {
+			logx.Errorf("订阅缓存失效频道失败，频道：%s，错误：%v", i.channel, err)
+			if !i.wait() {
+				return
+			}
+			continue
+		}
+
+		i.lock.Lock()
+		select {
+		case <-i.done:
+			i.lock.Unlock()
+			pubsub.Close()
+			return
+		default:
+			i.pubsub = pubsub
+		}
+		i.lock.Unlock()
+
+		i.receive(pubsub)
+	}
+}
+
+// receive 持续接收消息，出错时由 PubSub 在下次接收时重连并重新订阅，关闭后返回
+func (i *invalidator) receive(pubsub *redis.PubSub) {
+	for {
+		msg, err := pubsub.ReceiveTimeout(invalidationPingInterval)
+		if err == nil {
+			i.handle(msg)
+			continue
+		}
+
+		if i.closed() {
+			return
+		}
+
+		if e, ok := err.(net.Error); ok && e.Timeout() {
+			// 长时间无消息，探测连接是否仍然可用
+			if err = pubsub.Ping(); err == nil {
+				continue
+			}
+		}
+
+		i.gap(err)
+		if !i.wait() {
+			return
+		}
+	}
+}
+
+// wait 等待重试间隔，已关闭时返回 false
+func (i *invalidator) wait() bool {
+	select {
+	case <-i.done:
+		return false
+	case <-time.After(invalidationRetryInterval):
+		return true
+	}
+}
+
+func (i *invalidator) closed() bool {
+	select {
+	case <-i.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// handle 处理订阅消息，(重新)订阅成功时清空本地缓存以弥补中断期间丢失的消息
+func (i *invalidator) handle(msg interface{}) {
+	switch m := msg.(type) {
+	case *redis.Subscription:
+		if m.Kind == "subscribe" {
+			i.local.flush()
+			i.ready.Set(true)
+		}
+	case *redis.Message:
+		var keys []string
+		if err := json.Unmarshal([]byte(m.Payload), &keys); err != nil {
+			logx.Errorf("无效的缓存失效消息，频道：%s，内容：%s", m.Channel, m.Payload)
+			i.local.flush()
+			return
+		}
+
+		i.local.invalidate(keys...)
+	}
+}
+
+// gap 订阅中断，停用并清空本地缓存
+func (i *invalidator) gap(err error) {
+	if i.ready.CompareAndSwap(true, false) {
+		logx.Errorf("缓存失效频道订阅中断，停用本地缓存，频道：%s，错误：%v", i.channel, err)
+	}
+	i.local.flush()
+}
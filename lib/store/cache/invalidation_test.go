@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/z-sdk/goa/lib/store/redis"
+	"github.com/z-sdk/goa/lib/syncx"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestInvalidator_Handle(t *testing.T) {
+	next := &mockedNode{vals: make(map[string][]byte), errNotFound: errors.New("not found")}
+	local := newLocalCache(next, &Stat{name: "invalidation"}, newOptions(WithLocalCache(10, time.Minute)))
+	local.invalidator = newInvalidator(nil, "cache_invalidation", local)
+
+	var queries int
+	take := func(key string) {
+		var value string
+		assert.Nil(t, local.Take(&value, key, func(v interface{}) error {
+			queries++
+			*v.(*string) = key
+			return nil
+		}))
+	}
+
+	// 订阅成功前不使用本地缓存
+	take("a")
+	assert.Equal(t, 0, local.lru.Len())
+
+	local.invalidator.handle(&redis.Subscription{Kind: "subscribe", Channel: "cache_invalidation", Count: 1})
+	take("a")
+	take("b")
+	take("a")
+	assert.Equal(t, 2, local.lru.Len())
+	assert.Equal(t, uint64(1), local.stat.LocalHit)
+
+	// 其他进程发布的失效消息淘汰对应的键
+	local.invalidator.handle(&redis.Message{Channel: "cache_invalidation", Payload: `["a","c"]`})
+	_, ok := local.items["a"]
+	assert.False(t, ok)
+	_, ok = local.items["b"]
+	assert.True(t, ok)
+
+	// 无法解析的消息清空本地缓存
+	local.invalidator.handle(&redis.Message{Channel: "cache_invalidation", Payload: "a"})
+	assert.Equal(t, 0, local.lru.Len())
+
+	// 订阅中断后停用并清空本地缓存，重新订阅后恢复
+	take("b")
+	local.invalidator.gap(errors.New("broken pipe"))
+	assert.False(t, local.invalidator.ready.True())
+	assert.Equal(t, 0, local.lru.Len())
+	take("b")
+	assert.Equal(t, 0, local.lru.Len())
+
+	local.invalidator.handle(&redis.Subscription{Kind: "subscribe", Channel: "cache_invalidation", Count: 1})
+	take("b")
+	assert.Equal(t, 1, local.lru.Len())
+	assert.Equal(t, uint64(1), local.stat.LocalHit)
+	assert.Equal(t, 2, queries)
+}
+
+func TestInvalidator_HandleDuringTake(t *testing.T) {
+	next := &mockedNode{vals: make(map[string][]byte), errNotFound: errors.New("not found")}
+	local := newLocalCache(next, &Stat{name: "invalidation"}, newOptions(WithLocalCache(10, time.Minute)))
+	local.invalidator = newInvalidator(nil, "cache_invalidation", local)
+	local.invalidator.handle(&redis.Subscription{Kind: "subscribe", Channel: "cache_invalidation", Count: 1})
+
+	// takeDuring 在从下层读取 key 期间执行 fn
+	takeDuring := func(key string, fn func()) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		done := make(chan error)
+		go func() {
+			var value string
+			done <- local.Take(&value, key, func(v interface{}) error {
+				close(started)
+				<-release
+				*v.(*string) = "old"
+				return nil
+			})
+		}()
+		<-started
+		fn()
+		close(release)
+		assert.Nil(t, <-done)
+	}
+
+	// 读取期间收到其他进程的失效消息，读到的旧值不写入本地缓存
+	takeDuring("a", func() {
+		local.invalidator.handle(&redis.Message{Channel: "cache_invalidation", Payload: `["a"]`})
+	})
+	_, ok := local.items["a"]
+	assert.False(t, ok)
+
+	// 读取期间订阅中断并恢复，中断期间可能丢失了失效消息，同样不写入
+	takeDuring("b", func() {
+		local.invalidator.gap(errors.New("broken pipe"))
+		local.invalidator.handle(&redis.Subscription{Kind: "subscribe", Channel: "cache_invalidation", Count: 1})
+	})
+	_, ok = local.items["b"]
+	assert.False(t, ok)
+
+	// 无关的键不受影响
+	takeDuring("c", func() {
+		if generationSlot("a") != generationSlot("c") {
+			local.invalidator.handle(&redis.Message{Channel: "cache_invalidation", Payload: `["a"]`})
+		}
+	})
+	_, ok = local.items["c"]
+	assert.True(t, ok)
+}
+
+func TestInvalidator_Close(t *testing.T) {
+	// 接受连接但从不应答的服务端，订阅的接收一直阻塞
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	var conns int32
+	released := make(chan struct{}, 16)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&conns, 1)
+			go func() {
+				io.Copy(ioutil.Discard, conn)
+				conn.Close()
+				released <- struct{}{}
+			}()
+		}
+	}()
+
+	r := redis.NewRedis(listener.Addr().String(), redis.StandaloneMode)
+	c := NewCacheNode(r, syncx.NewSharedCalls(), &Stat{name: "invalidation"}, errors.New("not found"),
+		WithLocalCache(10, time.Minute), WithInvalidateChannel("cache_invalidation_close"))
+	closer, ok := c.(io.Closer)
+	assert.True(t, ok)
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&conns) > 0
+	}, time.Second, 10*time.Millisecond)
+
+	// 关闭时结束阻塞中的接收并释放订阅连接
+	done := make(chan error)
+	go func() {
+		done <- closer.Close()
+	}()
+	select {
+	case err = <-done:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("关闭时应结束订阅")
+	}
+	select {
+	case <-released:
+	case <-time.After(time.Second):
+		t.Fatal("关闭时应释放订阅连接")
+	}
+	assert.False(t, c.(*localCache).invalidator.ready.True())
+	assert.Nil(t, closer.Close())
+
+	// 未配置失效频道时关闭不做任何事
+	c = NewCacheNode(r, syncx.NewSharedCalls(), &Stat{name: "invalidation"}, errors.New("not found"),
+		WithLocalCache(10, time.Minute))
+	assert.Nil(t, c.(io.Closer).Close())
+}
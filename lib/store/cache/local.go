@@ -0,0 +1,328 @@
+package cache
+
+import (
+	"container/list"
+	"github.com/z-sdk/goa/lib/hash"
+	"github.com/z-sdk/goa/lib/store/redis"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const (
+	defaultLocalExpires = time.Minute // 本地缓存默认有效期
+	localGenerations    = 256         // 失效代数的分片数
+)
+
+type (
+	// localCache 进程内的一级缓存，容量有限，按最近最少使用淘汰，条目到期后失效。
+	// 仅由 Take/TakeEx 写入，Set/SetEx/Del 时失效对应条目，未命中时交由下层 redis 缓存处理。
+	// 配置了失效频道时，还会通知其他进程淘汰各自的本地缓存。
+	// 失效时递增键所在分片的代数，从下层读取期间代数有变化的值不写入本地缓存，以免写回已失效的旧值
+	localCache struct {
+		Cache
+		limit       int
+		expires     time.Duration
+		stat        *Stat
+		codec       codec
+		invalidator *invalidator
+		lock        sync.Mutex
+		lru         *list.List
+		items       map[string]*list.Element
+		generations [localGenerations]uint64
+	}
+
+	localEntry struct {
+		key      string
+		value    []byte
+		expireAt time.Time
+	}
+)
+
+func newLocalCache(next Cache, stat *Stat, o Options) *localCache {
+	expires := o.LocalExpires
+	if expires <= 0 {
+		expires = defaultLocalExpires
+	}
+
+	return &localCache{
+		Cache:   next,
+		limit:   o.LocalSize,
+		expires: expires,
+		stat:    stat,
+		// 本地缓存无需压缩
+		codec: codec{serializer: o.Serializer},
+		lru:   list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// withLocalCache 按配置在下层缓存前加一层本地缓存，r 用于发布订阅失效消息
+func withLocalCache(next Cache, r *redis.Redis, stat *Stat, o Options) Cache {
+	if o.LocalSize <= 0 {
+		return next
+	}
+
+	local := newLocalCache(next, stat, o)
+	if len(o.InvalidateChannel) > 0 {
+		local.invalidator = newInvalidator(r, o.InvalidateChannel, local)
+		local.invalidator.start()
+	}
+
+	return local
+}
+
+// Close 取消失效频道的订阅并释放订阅连接，之后不再使用本地缓存，未配置失效频道时不做任何事
+func (c *localCache) Close() error {
+	if c.invalidator != nil {
+		c.invalidator.close()
+	}
+
+	return nil
+}
+
+func (c *localCache) Del(keys ...string) error {
+	c.invalidate(keys...)
+	err := c.Cache.Del(keys...)
+	c.notify(keys...)
+	return err
+}
+
+func (c *localCache) Get(key string, dest interface{}) error {
+	if c.getLocal(key, dest) {
+		return nil
+	}
+
+	return c.Cache.Get(key, dest)
+}
+
+func (c *localCache) Set(key string, value interface{}) error {
+	c.invalidate(key)
+	err := c.Cache.Set(key, value)
+	c.notify(key)
+	return err
+}
+
+func (c *localCache) SetEx(key string, value interface{}, expires time.Duration) error {
+	c.invalidate(key)
+	err := c.Cache.SetEx(key, value, expires)
+	c.notify(key)
+	return err
+}
+
+func (c *localCache) Take(dest interface{}, key string, queryFn func(interface{}) error) error {
+	if c.getLocal(key, dest) {
+		return nil
+	}
+
+	generation := c.generation(key)
+	if err := c.Cache.Take(dest, key, queryFn); err != nil {
+		return err
+	}
+
+	c.setLocal(key, dest, generation)
+	return nil
+}
+
+func (c *localCache) TakeEx(dest interface{}, key string, queryFn func(interface{}, time.Duration) error) error {
+	if c.getLocal(key, dest) {
+		return nil
+	}
+
+	generation := c.generation(key)
+	if err := c.Cache.TakeEx(dest, key, queryFn); err != nil {
+		return err
+	}
+
+	c.setLocal(key, dest, generation)
+	return nil
+}
+
+func (c *localCache) GetMany(keys []string, dest interface{}) error {
+	return c.many(keys, dest, false, func(rest []string, restDest interface{}) error {
+		return c.Cache.GetMany(rest, restDest)
+	})
+}
+
+func (c *localCache) TakeMany(keys []string, dest interface{},
+	queryMissing func(missingKeys []string) (map[string]interface{}, error)) error {
+	return c.many(keys, dest, true, func(rest []string, restDest interface{}) error {
+		return c.Cache.TakeMany(rest, restDest, queryMissing)
+	})
+}
+
+// many 先从本地缓存批量读取，其余的键交由下层缓存，populate 表示是否将下层读到的值写入本地缓存
+func (c *localCache) many(keys []string, dest interface{}, populate bool,
+	fn func(rest []string, restDest interface{}) error) error {
+	slice, err := makeDestSlice(dest, len(keys))
+	if err != nil {
+		return err
+	}
+
+	var rest []string
+	var indexes []int
+	var generations []uint64
+	for i, key := range keys {
+		if !c.getLocal(key, slice.Index(i).Addr().Interface()) {
+			rest = append(rest, key)
+			indexes = append(indexes, i)
+			generations = append(generations, c.generation(key))
+		}
+	}
+	if len(rest) == 0 {
+		return nil
+	}
+
+	restDest := reflect.New(slice.Type())
+	if err = fn(rest, restDest.Interface()); err != nil {
+		return err
+	}
+
+	restSlice := restDest.Elem()
+	for i, index := range indexes {
+		elem := restSlice.Index(i)
+		slice.Index(index).Set(elem)
+		// 零值表示未命中或不存在，不写入本地缓存
+		if populate && !elem.IsZero() {
+			c.setLocal(rest[i], elem.Interface(), generations[i])
+		}
+	}
+
+	return nil
+}
+
+// enabled 本地缓存是否可用，失效订阅中断时不可用
+func (c *localCache) enabled() bool {
+	return c.invalidator == nil || c.invalidator.ready.True()
+}
+
+// notify 通知其他进程淘汰本地缓存
+func (c *localCache) notify(keys ...string) {
+	if c.invalidator != nil {
+		c.invalidator.publish(keys...)
+	}
+}
+
+// getLocal 从本地缓存读取，命中时计入本地命中数
+func (c *localCache) getLocal(key string, dest interface{}) bool {
+	if !c.enabled() {
+		return false
+	}
+
+	c.lock.Lock()
+	elem, ok := c.items[key]
+	if !ok {
+		c.lock.Unlock()
+		return false
+	}
+
+	entry := elem.Value.(*localEntry)
+	if time.Now().After(entry.expireAt) {
+		c.removeElement(elem)
+		c.lock.Unlock()
+		return false
+	}
+	c.lru.MoveToFront(elem)
+	value := entry.value
+	c.lock.Unlock()
+
+	// 值已序列化，调用方拿到的是副本，互不影响
+	if err := c.codec.unmarshal(value, dest); err != nil {
+		c.remove(key)
+		return false
+	}
+
+	c.stat.IncrTotal()
+	c.stat.IncrLocalHit()
+	return true
+}
+
+// generation 返回键所在分片的失效代数，须在从下层读取之前获取
+func (c *localCache) generation(key string) uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.generations[generationSlot(key)]
+}
+
+// setLocal 写入本地缓存，超出容量时淘汰最久未用的条目，
+// generation 为从下层读取前获取的代数，期间键已失效时不写入
+func (c *localCache) setLocal(key string, value interface{}, generation uint64) {
+	if !c.enabled() {
+		return
+	}
+
+	data, err := c.codec.marshal(value)
+	if err != nil {
+		return
+	}
+
+	expireAt := time.Now().Add(c.expires)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.generations[generationSlot(key)] != generation {
+		return
+	}
+
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*localEntry)
+		entry.value = data
+		entry.expireAt = expireAt
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.lru.PushFront(&localEntry{
+		key:      key,
+		value:    data,
+		expireAt: expireAt,
+	})
+	for c.lru.Len() > c.limit {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+// flush 清空本地缓存，并使正在从下层读取的值都不再写入
+func (c *localCache) flush() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for i := range c.generations {
+		c.generations[i]++
+	}
+	c.lru.Init()
+	c.items = make(map[string]*list.Element)
+}
+
+// invalidate 淘汰本地缓存中的键，并使正在从下层读取的值不再写入
+func (c *localCache) invalidate(keys ...string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, key := range keys {
+		c.generations[generationSlot(key)]++
+		if elem, ok := c.items[key]; ok {
+			c.removeElement(elem)
+		}
+	}
+}
+
+func (c *localCache) remove(keys ...string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, key := range keys {
+		if elem, ok := c.items[key]; ok {
+			c.removeElement(elem)
+		}
+	}
+}
+
+func (c *localCache) removeElement(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.items, elem.Value.(*localEntry).key)
+}
+
+func generationSlot(key string) uint64 {
+	return hash.Hash([]byte(key)) % localGenerations
+}
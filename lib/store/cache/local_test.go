@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLocalCache_Take(t *testing.T) {
+	errNotFound := errors.New("not found")
+	next := &mockedNode{vals: make(map[string][]byte), errNotFound: errNotFound}
+	stat := &Stat{name: "local"}
+	c := newLocalCache(next, stat, newOptions(WithLocalCache(2, time.Minute)))
+
+	var queries int
+	take := func(key string) string {
+		var value string
+		assert.Nil(t, c.Take(&value, key, func(v interface{}) error {
+			queries++
+			*v.(*string) = "value-" + key
+			return nil
+		}))
+		return value
+	}
+
+	assert.Equal(t, "value-a", take("a"))
+	assert.Equal(t, "value-a", take("a"))
+	assert.Equal(t, 1, queries)
+	assert.Equal(t, uint64(1), stat.LocalHit)
+
+	// 下层缓存被改写后，本地缓存仍命中
+	assert.Nil(t, next.Set("a", "changed"))
+	assert.Equal(t, "value-a", take("a"))
+	assert.Equal(t, uint64(2), stat.LocalHit)
+
+	// 删除时本地缓存一并失效
+	assert.Nil(t, c.Del("a"))
+	assert.Equal(t, "value-a", take("a"))
+	assert.Equal(t, 2, queries)
+
+	// Set 使本地缓存失效，下次从下层读取
+	assert.Nil(t, c.Set("a", "new"))
+	assert.Equal(t, "new", take("a"))
+	var value string
+	assert.Nil(t, c.Get("a", &value))
+	assert.Equal(t, "new", value)
+	assert.Equal(t, uint64(3), stat.LocalHit)
+}
+
+func TestLocalCache_Evict(t *testing.T) {
+	errNotFound := errors.New("not found")
+	next := &mockedNode{vals: make(map[string][]byte), errNotFound: errNotFound}
+	c := newLocalCache(next, &Stat{name: "local"}, newOptions(WithLocalCache(2, time.Minute)))
+
+	for _, key := range []string{"a", "b", "a", "c"} {
+		var value string
+		assert.Nil(t, c.TakeEx(&value, key, func(v interface{}, expires time.Duration) error {
+			*v.(*string) = key
+			return nil
+		}))
+	}
+
+	// b 最久未用，被淘汰
+	assert.Equal(t, 2, c.lru.Len())
+	_, ok := c.items["b"]
+	assert.False(t, ok)
+	_, ok = c.items["a"]
+	assert.True(t, ok)
+
+	// 过期的条目不再命中
+	c.items["a"].Value.(*localEntry).expireAt = time.Now().Add(-time.Second)
+	var value string
+	assert.False(t, c.getLocal("a", &value))
+	assert.Equal(t, 1, c.lru.Len())
+}
+
+func TestWithLocalCache(t *testing.T) {
+	next := &mockedNode{vals: make(map[string][]byte)}
+	assert.Equal(t, next, withLocalCache(next, nil, nil, newOptions()))
+
+	c := withLocalCache(next, nil, nil, newOptions(WithLocalCache(10, 0)))
+	local, ok := c.(*localCache)
+	assert.True(t, ok)
+	assert.Equal(t, 10, local.limit)
+	assert.Equal(t, defaultLocalExpires, local.expires)
+}
+
+func TestLocalCache_DelDuringTake(t *testing.T) {
+	errNotFound := errors.New("not found")
+	next := &mockedNode{vals: make(map[string][]byte), errNotFound: errNotFound}
+	c := newLocalCache(next, &Stat{name: "local"}, newOptions(WithLocalCache(10, time.Minute)))
+
+	// 从下层读取期间键被删除，读到的旧值不写入本地缓存
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		var value string
+		done <- c.Take(&value, "a", func(v interface{}) error {
+			close(started)
+			<-release
+			*v.(*string) = "old"
+			return nil
+		})
+	}()
+	<-started
+	// 下层还没有该键，mockedNode 删除时返回 errNotFound
+	assert.Equal(t, errNotFound, c.Del("a"))
+	close(release)
+	assert.Nil(t, <-done)
+	_, ok := c.items["a"]
+	assert.False(t, ok)
+
+	// 批量读取同样如此
+	started = make(chan struct{})
+	release = make(chan struct{})
+	go func() {
+		var values []string
+		done <- c.TakeMany([]string{"b", "c"}, &values, func(keys []string) (map[string]interface{}, error) {
+			close(started)
+			<-release
+			return map[string]interface{}{"b": "old", "c": "new"}, nil
+		})
+	}()
+	<-started
+	assert.Nil(t, c.Set("b", "changed"))
+	close(release)
+	assert.Nil(t, <-done)
+	_, ok = c.items["b"]
+	assert.False(t, ok)
+	_, ok = c.items["c"]
+	assert.Equal(t, generationSlot("b") != generationSlot("c"), ok)
+
+	// 之后的读取正常写入本地缓存
+	var value string
+	assert.Nil(t, c.Take(&value, "a", func(v interface{}) error {
+		return errors.New("should not query")
+	}))
+	assert.Equal(t, "old", value)
+	_, ok = c.items["a"]
+	assert.True(t, ok)
+}
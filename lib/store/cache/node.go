@@ -1,7 +1,6 @@
 package cache
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/z-sdk/goa/lib/logx"
@@ -9,7 +8,9 @@ import (
 	"github.com/z-sdk/goa/lib/stat"
 	"github.com/z-sdk/goa/lib/store/redis"
 	"github.com/z-sdk/goa/lib/syncx"
+	"github.com/z-sdk/goa/lib/threading"
 	"math/rand"
+	"reflect"
 	"sync"
 	"time"
 )
@@ -17,6 +18,7 @@ import (
 const (
 	expiresDeviation    = 0.05 // 过期偏差
 	notFoundPlaceholder = "*"  // 空记录占位符，防止缓存穿透
+	earlyRefreshRatio   = 0.1  // 软过期前的这段比例内按概率提前刷新，越临近软过期概率越高
 )
 
 var errPlaceholder = errors.New("placeholder")
@@ -27,7 +29,10 @@ type node struct {
 	expires         time.Duration
 	notFoundExpires time.Duration
 	unstableExpires mathx.Unstable
+	softExpires     time.Duration
+	refreshing      *sync.Map // 正在后台刷新的键
 	stat            *Stat
+	codec           codec
 	rnd             *rand.Rand
 	lock            *sync.Mutex
 	errNotFound     error
@@ -35,13 +40,20 @@ type node struct {
 
 func NewCacheNode(r *redis.Redis, barrier syncx.SharedCalls, stat *Stat, errNotFound error, opts ...Option) Cache {
 	o := newOptions(opts...)
+	return withLocalCache(newCacheNode(r, barrier, stat, errNotFound, o), r, stat, o)
+}
+
+func newCacheNode(r *redis.Redis, barrier syncx.SharedCalls, stat *Stat, errNotFound error, o Options) node {
 	return node{
 		redis:           r,
 		barrier:         barrier,
 		expires:         o.Expires,
 		notFoundExpires: o.NotFoundExpires,
 		unstableExpires: mathx.NewUnstable(expiresDeviation),
+		softExpires:     o.SoftExpires,
+		refreshing:      new(sync.Map),
 		stat:            stat,
+		codec:           newCodec(o),
 		rnd:             rand.New(rand.NewSource(time.Now().UnixNano())),
 		lock:            new(sync.Mutex),
 		errNotFound:     errNotFound,
@@ -62,7 +74,7 @@ func (n node) Del(keys ...string) error {
 }
 
 func (n node) Get(key string, dest interface{}) error {
-	if err := n.doGet(key, dest); err == errPlaceholder {
+	if _, err := n.doGet(key, dest); err == errPlaceholder {
 		return n.errNotFound
 	} else {
 		return err
@@ -74,7 +86,7 @@ func (n node) Set(key string, value interface{}) error {
 }
 
 func (n node) SetEx(key string, value interface{}, expires time.Duration) error {
-	data, err := json.Marshal(value)
+	data, err := n.codec.marshal(value)
 	if err != nil {
 		return err
 	}
@@ -82,9 +94,9 @@ func (n node) SetEx(key string, value interface{}, expires time.Duration) error
 	return n.redis.SetEx(key, string(data), int(expires.Seconds()))
 }
 
-// Take 拿key对应的dest缓存，拿不到缓存就查库并缓存
+// Take 拿key对应的dest缓存，拿不到缓存就查库并缓存，启用软过期时缓存软过期后返回旧值并在后台刷新
 func (n node) Take(dest interface{}, key string, queryFn func(interface{}) error) error {
-	return n.doTake(dest, key, queryFn, func(value interface{}) error {
+	return n.doTake(dest, key, n.softExpires > 0, queryFn, func(value interface{}) error {
 		return n.Set(key, value)
 	})
 }
@@ -92,7 +104,7 @@ func (n node) Take(dest interface{}, key string, queryFn func(interface{}) error
 // Take 读不到就写并设置有效期，然后返回
 func (n node) TakeEx(dest interface{}, key string, queryFn func(interface{}, time.Duration) error) error {
 	expires := n.aroundDuration(n.expires)
-	return n.doTake(dest, key, func(value interface{}) error {
+	return n.doTake(dest, key, false, func(value interface{}) error {
 		// 读库
 		return queryFn(value, expires)
 	}, func(newVal interface{}) error {
@@ -101,6 +113,15 @@ func (n node) TakeEx(dest interface{}, key string, queryFn func(interface{}, tim
 	})
 }
 
+func (n node) GetMany(keys []string, dest interface{}) error {
+	return getMany(n, n.codec, keys, dest)
+}
+
+func (n node) TakeMany(keys []string, dest interface{},
+	queryMissing func(missingKeys []string) (map[string]interface{}, error)) error {
+	return takeMany(n, n.codec, n.stat, keys, dest, queryMissing)
+}
+
 func (n node) String() string {
 	return n.redis.Addr
 }
@@ -112,31 +133,38 @@ func (n node) asyncRetryDelCache(keys ...string) {
 	}, keys...)
 }
 
-func (n node) doGet(key string, dest interface{}) error {
+// doGet 读取缓存写入 dest，并返回缓存值的软过期时间，未设置时为零值
+func (n node) doGet(key string, dest interface{}) (time.Time, error) {
 	n.stat.IncrTotal()
 	result, err := n.redis.Get(key)
 	if err != nil {
 		n.stat.IncrMiss()
-		return err
+		return time.Time{}, err
 	}
 
 	if len(result) == 0 {
 		n.stat.IncrMiss()
-		return n.errNotFound
+		return time.Time{}, n.errNotFound
 	}
 
 	n.stat.IncrHit()
 	if result == notFoundPlaceholder {
-		return errPlaceholder
+		return time.Time{}, errPlaceholder
 	}
 
 	return n.processCache(key, result, dest)
 }
 
-func (n node) doTake(dest interface{}, key string, queryFn func(newVal interface{}) error, cacheValFn func(newVal interface{}) error) error {
+// doTake revalidate 表示缓存软过期时是否在后台刷新
+func (n node) doTake(dest interface{}, key string, revalidate bool, queryFn func(newVal interface{}) error,
+	cacheValFn func(newVal interface{}) error) error {
 	// 防缓存击穿 barrier -> SharedCalls
 	result, hit, err := n.barrier.Do(key, func() (interface{}, error) {
-		if err := n.doGet(key, dest); err != nil {
+		softDeadline, err := n.doGet(key, dest)
+		if err == nil && revalidate && n.shouldRefresh(softDeadline) {
+			n.refresh(key, dest, queryFn, cacheValFn)
+		}
+		if err != nil {
 			if err == errPlaceholder {
 				return nil, n.errNotFound
 			} else if err != n.errNotFound {
@@ -163,7 +191,7 @@ func (n node) doTake(dest interface{}, key string, queryFn func(newVal interface
 			}
 		}
 
-		return json.Marshal(dest)
+		return n.codec.serializer.Marshal(dest)
 	})
 	if err != nil {
 		return err
@@ -176,13 +204,59 @@ func (n node) doTake(dest interface{}, key string, queryFn func(newVal interface
 	n.stat.IncrTotal()
 	n.stat.IncrHit()
 
-	return json.Unmarshal(result.([]byte), dest)
+	return n.codec.serializer.Unmarshal(result.([]byte), dest)
+}
+
+// shouldRefresh 软过期后须刷新，软过期前的最后一段时间内按概率提前刷新，以免热点键同时软过期
+func (n node) shouldRefresh(softDeadline time.Time) bool {
+	if softDeadline.IsZero() {
+		return false
+	}
+
+	remain := time.Until(softDeadline)
+	if remain <= 0 {
+		return true
+	}
+
+	window := time.Duration(float64(n.softExpires) * earlyRefreshRatio)
+	if remain >= window {
+		return false
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return n.rnd.Float64() > float64(remain)/float64(window)
+}
+
+// refresh 在后台查库刷新缓存，同一个键同时只有一个协程刷新，
+// 查库写入新分配的值，不影响调用方已拿到的旧值
+func (n node) refresh(key string, dest interface{}, queryFn func(newVal interface{}) error,
+	cacheValFn func(newVal interface{}) error) {
+	if _, loaded := n.refreshing.LoadOrStore(key, struct{}{}); loaded {
+		return
+	}
+
+	threading.GoSafe(func() {
+		defer n.refreshing.Delete(key)
+
+		value := reflect.New(reflect.TypeOf(dest).Elem()).Interface()
+		if err := queryFn(value); err == n.errNotFound {
+			if err = n.setWithNotFound(key); err != nil {
+				logx.Error(err)
+			}
+		} else if err != nil {
+			n.stat.IncrDbFails()
+			logx.Errorf("后台刷新缓存失败，节点：%s，键：%s，错误：%v", n.redis.Addr, key, err)
+		} else if err = cacheValFn(value); err != nil {
+			logx.Error(err)
+		}
+	})
 }
 
-func (n node) processCache(key string, result string, dest interface{}) error {
-	err := json.Unmarshal([]byte(result), dest)
+func (n node) processCache(key string, result string, dest interface{}) (time.Time, error) {
+	softDeadline, err := n.codec.unmarshalSoft([]byte(result), dest)
 	if err == nil {
-		return nil
+		return softDeadline, nil
 	}
 
 	msg := fmt.Sprintf("解封缓存失败，缓存节点：%s，键：%s，值：%s，错误：%v", n.redis.Addr, key, result, err)
@@ -193,7 +267,42 @@ func (n node) processCache(key string, result string, dest interface{}) error {
 	}
 
 	// 返回 errNotFound 以通过 queryFn 重新加载缓存值
-	return n.errNotFound
+	return time.Time{}, n.errNotFound
+}
+
+// mget 一次 MGET 批量读取
+func (n node) mget(keys []string) ([]string, error) {
+	values, err := n.redis.MGet(keys...)
+	for i := range keys {
+		n.stat.IncrTotal()
+		if err != nil || len(values[i]) == 0 {
+			n.stat.IncrMiss()
+		} else {
+			n.stat.IncrHit()
+		}
+	}
+
+	return values, err
+}
+
+// mset 以管道批量缓存，过期时间与单个缓存一致
+func (n node) mset(values map[string]string, notFound []string) {
+	if len(values) == 0 && len(notFound) == 0 {
+		return
+	}
+
+	err := n.redis.Pipelined(func(p redis.Pipeliner) error {
+		for key, value := range values {
+			p.Set(key, value, n.aroundDuration(n.expires))
+		}
+		for _, key := range notFound {
+			p.Set(key, notFoundPlaceholder, n.aroundDuration(n.notFoundExpires))
+		}
+		return nil
+	})
+	if err != nil {
+		logx.Errorf("批量缓存失败，节点：%s，错误：%v", n.redis.Addr, err)
+	}
 }
 
 // 防缓存雪崩：基于指定时间生成一个随机临近值，以防N多缓存同时过期，瞬间冲击数据库压力
@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"errors"
+	"github.com/alicebob/miniredis"
+	"github.com/stretchr/testify/assert"
+	"github.com/z-sdk/goa/lib/store/redis"
+	"github.com/z-sdk/goa/lib/syncx"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCodec_SoftExpires(t *testing.T) {
+	for _, cd := range []codec{
+		{serializer: JsonSerializer, softExpires: time.Minute},
+		{serializer: GobSerializer, softExpires: time.Minute, compressThreshold: 1},
+	} {
+		data, err := cd.marshal("kevin")
+		assert.Nil(t, err)
+		assert.Equal(t, formatMarker, data[0])
+
+		var name string
+		deadline, err := cd.unmarshalSoft(data, &name)
+		assert.Nil(t, err)
+		assert.Equal(t, "kevin", name)
+		assert.True(t, deadline.After(time.Now().Add(time.Minute-time.Second)))
+
+		// 未启用软过期的节点同样可以读取
+		name = ""
+		assert.Nil(t, codec{serializer: JsonSerializer}.unmarshal(data, &name))
+		assert.Equal(t, "kevin", name)
+	}
+
+	deadline, err := codec{serializer: JsonSerializer}.unmarshalSoft([]byte(`"kevin"`), new(string))
+	assert.Nil(t, err)
+	assert.True(t, deadline.IsZero())
+
+	_, err = codec{serializer: JsonSerializer}.unmarshalSoft([]byte{formatMarker, FormatJson | formatSoftExpires, 1}, new(string))
+	assert.Equal(t, errInvalidFormat, err)
+}
+
+func TestNode_ShouldRefresh(t *testing.T) {
+	n := newCacheNode(nil, nil, &Stat{name: "refresh"}, errors.New("not found"), newOptions(WithSoftExpires(time.Minute)))
+	assert.False(t, n.shouldRefresh(time.Time{}))
+	assert.True(t, n.shouldRefresh(time.Now().Add(-time.Second)))
+	assert.False(t, n.shouldRefresh(time.Now().Add(time.Minute)))
+
+	var refreshed int
+	for i := 0; i < 1000; i++ {
+		if n.shouldRefresh(time.Now().Add(time.Second)) {
+			refreshed++
+		}
+	}
+	assert.True(t, refreshed > 0)
+}
+
+func TestNode_SoftExpires(t *testing.T) {
+	s, err := miniredis.Run()
+	assert.Nil(t, err)
+	defer s.Close()
+
+	r := redis.NewRedis(s.Addr(), redis.StandaloneMode)
+	c := NewCacheNode(r, syncx.NewSharedCalls(), &Stat{name: "soft"}, errors.New("not found"),
+		WithSoftExpires(100*time.Millisecond))
+
+	var version int32
+	release := make(chan struct{})
+	queryFn := func(v interface{}) error {
+		if atomic.AddInt32(&version, 1) > 1 {
+			<-release
+		}
+		*v.(*int32) = atomic.LoadInt32(&version)
+		return nil
+	}
+
+	var value int32
+	assert.Nil(t, c.Take(&value, "any", queryFn))
+	assert.Equal(t, int32(1), value)
+
+	time.Sleep(150 * time.Millisecond)
+
+	// 软过期后立即返回旧值，只有一个协程在后台刷新
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var value int32
+			assert.Nil(t, c.Take(&value, "any", queryFn))
+			assert.Equal(t, int32(1), value)
+		}()
+	}
+	wg.Wait()
+	close(release)
+
+	assert.Eventually(t, func() bool {
+		var value int32
+		return c.Get("any", &value) == nil && value == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&version))
+	// 硬过期时间仍按 Expires 设置
+	assert.True(t, s.TTL("any") > time.Hour)
+}
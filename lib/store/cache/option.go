@@ -9,8 +9,14 @@ const (
 
 type (
 	Options struct {
-		Expires         time.Duration
-		NotFoundExpires time.Duration
+		Expires           time.Duration
+		NotFoundExpires   time.Duration
+		LocalSize         int           // 本地缓存容量，0 表示不启用本地缓存
+		LocalExpires      time.Duration // 本地缓存有效期
+		InvalidateChannel string        // 进程间同步本地缓存失效的 redis 频道，空表示不同步
+		Serializer        Serializer    // 缓存值的序列化器，默认 JSON
+		CompressThreshold int           // 序列化后超过该字节数时压缩，0 表示不压缩
+		SoftExpires       time.Duration // 软过期时间，0 表示不启用
 	}
 
 	Option func(o *Options)
@@ -28,6 +34,9 @@ func newOptions(opts ...Option) Options {
 	if o.NotFoundExpires <= 0 {
 		o.NotFoundExpires = defaultNotFoundExpires
 	}
+	if o.Serializer == nil {
+		o.Serializer = JsonSerializer
+	}
 
 	return o
 }
@@ -43,3 +52,45 @@ func WithNotFoundExpires(expires time.Duration) Option {
 		o.NotFoundExpires = expires
 	}
 }
+
+// WithLocalCache 在 redis 前加一层进程内缓存，最多缓存 size 个键，每个键 expires 后失效
+func WithLocalCache(size int, expires time.Duration) Option {
+	return func(o *Options) {
+		o.LocalSize = size
+		o.LocalExpires = expires
+	}
+}
+
+// WithInvalidateChannel 经 redis 频道在进程间同步本地缓存的失效，集群模式下使用首个节点收发消息，
+// 需与 WithLocalCache 同用，同一份缓存数据的所有进程应使用相同的频道。
+// 启用后返回的 Cache 实现了 io.Closer，生命周期短于程序时应关闭以取消订阅
+func WithInvalidateChannel(channel string) Option {
+	return func(o *Options) {
+		o.InvalidateChannel = channel
+	}
+}
+
+// WithSerializer 指定缓存值的序列化器，如 GobSerializer、BinarySerializer。
+// 缓存值带有格式标记，切换序列化器后旧格式的值仍可读取，无需清空 redis
+func WithSerializer(serializer Serializer) Option {
+	return func(o *Options) {
+		o.Serializer = serializer
+	}
+}
+
+// WithCompression 序列化后超过 threshold 字节的缓存值压缩后存储
+func WithCompression(threshold int) Option {
+	return func(o *Options) {
+		o.CompressThreshold = threshold
+	}
+}
+
+// WithSoftExpires 缓存值写入 expires 后软过期：Take 读到软过期的值时立即返回旧值，
+// 由一个协程在后台查库刷新，临近软过期时还会按概率提前刷新，硬过期时间不变。
+// 后台刷新时以新分配的值调用 queryFn，因此 queryFn 只能写入其参数；
+// 刷新在调用方返回后进行，依赖调用方上下文的查询可能被取消，此时保留旧值直到硬过期
+func WithSoftExpires(expires time.Duration) Option {
+	return func(o *Options) {
+		o.SoftExpires = expires
+	}
+}
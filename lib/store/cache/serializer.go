@@ -0,0 +1,202 @@
+package cache
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+const (
+	// 带格式标记的缓存值以 formatMarker 开头，其后一个字节为格式编号，最高位表示是否压缩，
+	// 次高位表示其后带有 8 字节的软过期时间（毫秒时间戳）。
+	// 合法的 JSON 不会以 0 字节开头，因此没有标记的值按旧版 JSON 解码
+	formatMarker      byte = 0
+	formatCompressed  byte = 0x80
+	formatSoftExpires byte = 0x40
+	formatFlags            = formatCompressed | formatSoftExpires
+	softExpiresSize        = 8
+
+	FormatJson   byte = 1 // JSON 格式编号
+	FormatGob    byte = 2 // gob 格式编号
+	FormatBinary byte = 3 // 紧凑二进制格式编号
+)
+
+var (
+	// JsonSerializer JSON 序列化器，默认使用，整数解码到 interface{} 时会变成 float64
+	JsonSerializer Serializer = jsonSerializer{}
+	// GobSerializer gob 序列化器，interface{} 类型的值需先 gob.Register
+	GobSerializer Serializer = gobSerializer{}
+	// BinarySerializer 紧凑二进制序列化器，结构体按导出字段的顺序编码，不含字段名
+	BinarySerializer Serializer = binarySerializer{}
+
+	builtinSerializers = map[byte]Serializer{
+		FormatJson:   JsonSerializer,
+		FormatGob:    GobSerializer,
+		FormatBinary: BinarySerializer,
+	}
+
+	errInvalidFormat = errors.New("无效的缓存值格式")
+)
+
+type (
+	// Serializer 缓存值的序列化器
+	Serializer interface {
+		// Format 格式编号，写入缓存值的格式标记中，取值 1~63，自定义格式不可与内置格式冲突
+		Format() byte
+		Marshal(v interface{}) ([]byte, error)
+		Unmarshal(data []byte, v interface{}) error
+	}
+
+	jsonSerializer struct{}
+
+	gobSerializer struct{}
+
+	// codec 缓存值编解码器，以配置的序列化器编码，解码时按格式标记识别内置格式，
+	// 因此切换序列化器后新旧格式的缓存值可以共存，无需清空 redis
+	codec struct {
+		serializer        Serializer
+		compressThreshold int           // 超过该字节数时压缩，0 表示不压缩
+		softExpires       time.Duration // 软过期时间，写入缓存值中，0 表示不设置
+	}
+)
+
+func (s jsonSerializer) Format() byte {
+	return FormatJson
+}
+
+func (s jsonSerializer) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (s jsonSerializer) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (s gobSerializer) Format() byte {
+	return FormatGob
+}
+
+func (s gobSerializer) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (s gobSerializer) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+func newCodec(o Options) codec {
+	return codec{
+		serializer:        o.Serializer,
+		compressThreshold: o.CompressThreshold,
+		softExpires:       o.SoftExpires,
+	}
+}
+
+// marshal 编码缓存值，未压缩且无软过期时间的 JSON 不加格式标记，与旧版缓存值保持一致
+func (c codec) marshal(v interface{}) ([]byte, error) {
+	data, err := c.serializer.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	format := c.serializer.Format()
+	if c.compressThreshold > 0 && len(data) > c.compressThreshold {
+		if compressed, err := compress(data); err == nil && len(compressed) < len(data) {
+			data = compressed
+			format |= formatCompressed
+		}
+	}
+	if c.softExpires > 0 {
+		format |= formatSoftExpires
+	}
+	if format == FormatJson {
+		return data, nil
+	}
+
+	header := []byte{formatMarker, format}
+	if c.softExpires > 0 {
+		var deadline [softExpiresSize]byte
+		binary.BigEndian.PutUint64(deadline[:], uint64(time.Now().Add(c.softExpires).UnixNano()/int64(time.Millisecond)))
+		header = append(header, deadline[:]...)
+	}
+
+	return append(header, data...), nil
+}
+
+// unmarshal 按格式标记解码缓存值
+func (c codec) unmarshal(data []byte, v interface{}) error {
+	_, err := c.unmarshalSoft(data, v)
+	return err
+}
+
+// unmarshalSoft 按格式标记解码缓存值，并返回软过期时间，未设置时为零值
+func (c codec) unmarshalSoft(data []byte, v interface{}) (time.Time, error) {
+	if len(data) == 0 || data[0] != formatMarker {
+		return time.Time{}, json.Unmarshal(data, v)
+	}
+	if len(data) < 2 {
+		return time.Time{}, errInvalidFormat
+	}
+
+	format := data[1] &^ formatFlags
+	serializer, ok := builtinSerializers[format]
+	if !ok {
+		if format != c.serializer.Format() {
+			return time.Time{}, fmt.Errorf("未知的缓存值格式 %d", format)
+		}
+		serializer = c.serializer
+	}
+
+	var softDeadline time.Time
+	payload := data[2:]
+	if data[1]&formatSoftExpires != 0 {
+		if len(payload) < softExpiresSize {
+			return time.Time{}, errInvalidFormat
+		}
+		millis := int64(binary.BigEndian.Uint64(payload))
+		softDeadline = time.Unix(0, millis*int64(time.Millisecond))
+		payload = payload[softExpiresSize:]
+	}
+	if data[1]&formatCompressed != 0 {
+		var err error
+		if payload, err = decompress(payload); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	return softDeadline, serializer.Unmarshal(payload, v)
+}
+
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(data); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func decompress(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+
+	return ioutil.ReadAll(r)
+}
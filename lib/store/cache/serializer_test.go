@@ -0,0 +1,184 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"github.com/alicebob/miniredis"
+	"github.com/stretchr/testify/assert"
+	"github.com/z-sdk/goa/lib/store/redis"
+	"github.com/z-sdk/goa/lib/syncx"
+	"strings"
+	"testing"
+	"time"
+)
+
+type serializedItem struct {
+	Id       int64
+	Name     string
+	Score    float64
+	Ratio    float32
+	Valid    bool
+	Data     []byte
+	Tags     []string
+	Attrs    map[string]int
+	Parent   *serializedItem
+	Created  time.Time
+	Counts   [2]uint16
+	internal int
+}
+
+func TestSerializers(t *testing.T) {
+	created := time.Date(2020, 10, 10, 8, 0, 0, 0, time.UTC)
+	item := serializedItem{
+		Id:      1 << 60,
+		Name:    "kevin",
+		Score:   99.5,
+		Ratio:   0.25,
+		Valid:   true,
+		Data:    []byte{0, 1, 2},
+		Tags:    []string{"a", "b"},
+		Attrs:   map[string]int{"x": -1},
+		Parent:  &serializedItem{Id: 2, Name: "jack"},
+		Created: created,
+		Counts:  [2]uint16{3, 4},
+	}
+
+	for _, serializer := range []Serializer{JsonSerializer, GobSerializer, BinarySerializer} {
+		data, err := serializer.Marshal(item)
+		assert.Nil(t, err)
+
+		var dest serializedItem
+		assert.Nil(t, serializer.Unmarshal(data, &dest))
+		assert.True(t, created.Equal(dest.Created))
+		dest.Created = created
+		dest.Parent.Created = time.Time{}
+		assert.Equal(t, item, dest)
+	}
+}
+
+func TestBinarySerializer(t *testing.T) {
+	// 整数解码到 interface{} 时保持原类型
+	data, err := BinarySerializer.Marshal(int64(2e6))
+	assert.Nil(t, err)
+	var id interface{}
+	assert.Nil(t, BinarySerializer.Unmarshal(data, &id))
+	assert.Equal(t, int64(2e6), id)
+
+	// 紧凑：不含字段名
+	jsonData, _ := JsonSerializer.Marshal(serializedItem{Id: 1, Name: "kevin"})
+	binaryData, _ := BinarySerializer.Marshal(serializedItem{Id: 1, Name: "kevin"})
+	assert.True(t, len(binaryData) < len(jsonData)/2)
+
+	var ptr *serializedItem
+	data, err = BinarySerializer.Marshal(ptr)
+	assert.Nil(t, err)
+	assert.Nil(t, BinarySerializer.Unmarshal(data, &ptr))
+	assert.Nil(t, ptr)
+
+	// 结构体定义与缓存值不符
+	data, _ = BinarySerializer.Marshal(struct{ A, B int }{1, 2})
+	var mismatch struct{ A int }
+	assert.NotNil(t, BinarySerializer.Unmarshal(data, &mismatch))
+	var str string
+	assert.NotNil(t, BinarySerializer.Unmarshal(data[:3], &str))
+	assert.NotNil(t, BinarySerializer.Unmarshal(data, mismatch))
+
+	_, err = BinarySerializer.Marshal(make(chan int))
+	assert.NotNil(t, err)
+}
+
+func TestCodec(t *testing.T) {
+	value := strings.Repeat("kevin", 100)
+
+	// 默认 JSON 不加格式标记，与旧版缓存值一致
+	jsonCodec := newCodec(newOptions())
+	data, err := jsonCodec.marshal(value)
+	assert.Nil(t, err)
+	assert.Equal(t, byte('"'), data[0])
+
+	compressed := newCodec(newOptions(WithCompression(100)))
+	compressedData, err := compressed.marshal(value)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{formatMarker, FormatJson | formatCompressed}, compressedData[:2])
+	assert.True(t, len(compressedData) < len(data))
+
+	// 未超过阈值的不压缩
+	small, err := compressed.marshal("kevin")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte(`"kevin"`), small)
+
+	binaryCodec := newCodec(newOptions(WithSerializer(BinarySerializer), WithCompression(100)))
+	binaryData, err := binaryCodec.marshal(value)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{formatMarker, FormatBinary | formatCompressed}, binaryData[:2])
+
+	gobCodec := newCodec(newOptions(WithSerializer(GobSerializer)))
+	gobData, err := gobCodec.marshal(value)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{formatMarker, FormatGob}, gobData[:2])
+
+	// 任一编解码器都能读取各种格式，切换序列化器无需清空缓存
+	for _, c := range []codec{jsonCodec, binaryCodec, gobCodec} {
+		for _, d := range [][]byte{data, compressedData, binaryData, gobData} {
+			var dest string
+			assert.Nil(t, c.unmarshal(d, &dest))
+			assert.Equal(t, value, dest)
+		}
+	}
+
+	var dest string
+	assert.Equal(t, errInvalidFormat, jsonCodec.unmarshal([]byte{formatMarker}, &dest))
+	assert.NotNil(t, jsonCodec.unmarshal([]byte{formatMarker, 99, 1}, &dest))
+}
+
+type upperSerializer struct{}
+
+func (s upperSerializer) Format() byte {
+	return 16
+}
+
+func (s upperSerializer) Marshal(v interface{}) ([]byte, error) {
+	return bytes.ToUpper([]byte(v.(string))), nil
+}
+
+func (s upperSerializer) Unmarshal(data []byte, v interface{}) error {
+	*v.(*string) = string(data)
+	return nil
+}
+
+func TestNode_Serializer(t *testing.T) {
+	s, err := miniredis.Run()
+	assert.Nil(t, err)
+	defer s.Close()
+
+	r := redis.NewRedis(s.Addr(), redis.StandaloneMode)
+	errNotFound := errors.New("not found")
+	c := NewCacheNode(r, syncx.NewSharedCalls(), &Stat{name: "serializer"}, errNotFound,
+		WithSerializer(BinarySerializer))
+
+	// 旧版 JSON 缓存值仍可读取
+	assert.Nil(t, s.Set("legacy", `{"Id":1,"Name":"kevin"}`))
+	var item serializedItem
+	assert.Nil(t, c.Get("legacy", &item))
+	assert.Equal(t, serializedItem{Id: 1, Name: "kevin"}, item)
+
+	var id interface{}
+	assert.Nil(t, c.Take(&id, "id", func(v interface{}) error {
+		*v.(*interface{}) = int64(2e6)
+		return nil
+	}))
+	id = nil
+	assert.Nil(t, c.Get("id", &id))
+	assert.Equal(t, int64(2e6), id)
+	val, err := s.Get("id")
+	assert.Nil(t, err)
+	assert.Equal(t, string([]byte{formatMarker, FormatBinary}), val[:2])
+
+	// 自定义格式
+	c = NewCacheNode(r, syncx.NewSharedCalls(), &Stat{name: "serializer"}, errNotFound,
+		WithSerializer(upperSerializer{}))
+	assert.Nil(t, c.Set("custom", "kevin"))
+	var name string
+	assert.Nil(t, c.Get("custom", &name))
+	assert.Equal(t, "KEVIN", name)
+}
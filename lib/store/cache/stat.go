@@ -10,11 +10,12 @@ const statInterval = time.Minute // 缓存统计周期
 
 // Stat 缓存统计
 type Stat struct {
-	name    string
-	Total   uint64 // 请求数
-	Hit     uint64 // 命中数
-	Miss    uint64 // 错过数
-	DbFails uint64 // 查库失败次数
+	name     string
+	Total    uint64 // 请求数
+	Hit      uint64 // 命中数
+	LocalHit uint64 // 本地缓存命中数
+	Miss     uint64 // 错过数
+	DbFails  uint64 // 查库失败次数
 }
 
 func NewCacheStat(name string) *Stat {
@@ -36,11 +37,12 @@ func (s *Stat) Loop() {
 			}
 
 			hit := atomic.SwapUint64(&s.Hit, 0)
-			percent := 100 * float32(hit) / float32(total)
+			localHit := atomic.SwapUint64(&s.LocalHit, 0)
+			percent := 100 * float32(hit+localHit) / float32(total)
 			miss := atomic.SwapUint64(&s.Miss, 0)
 			dbf := atomic.SwapUint64(&s.DbFails, 0)
-			logx.Statf("dbcache(%s) - qpm: %d, hit_ratio: %.1f%%, hit: %d, miss: %d, db_fails: %d",
-				s.name, total, percent, hit, miss, dbf)
+			logx.Statf("dbcache(%s) - qpm: %d, hit_ratio: %.1f%%, hit: %d, local_hit: %d, miss: %d, db_fails: %d",
+				s.name, total, percent, hit, localHit, miss, dbf)
 		}
 	}
 }
@@ -53,6 +55,10 @@ func (s *Stat) IncrHit() {
 	atomic.AddUint64(&s.Hit, 1)
 }
 
+func (s *Stat) IncrLocalHit() {
+	atomic.AddUint64(&s.LocalHit, 1)
+}
+
 func (s *Stat) IncrMiss() {
 	atomic.AddUint64(&s.Miss, 1)
 }
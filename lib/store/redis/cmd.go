@@ -609,6 +609,21 @@ func (r *Redis) Pipelined(fn func(Pipeliner) error) (err error) {
 	return
 }
 
+// Publish 向频道发布消息，返回收到消息的订阅者个数
+func (r *Redis) Publish(channel string, message interface{}) (val int64, err error) {
+	err = r.brk.DoWithAcceptable(func() error {
+		client, err := getClient(r)
+		if err != nil {
+			return err
+		}
+
+		val, err = client.Publish(channel, message).Result()
+		return err
+	}, acceptable)
+
+	return
+}
+
 // RPush 从右侧向 key 对应列表中插入一组值
 func (r *Redis) RPush(key string, values ...interface{}) (val int, err error) {
 	err = r.brk.DoWithAcceptable(func() error {
@@ -628,6 +643,23 @@ func (r *Redis) RPush(key string, values ...interface{}) (val int, err error) {
 	return
 }
 
+// Subscribe 订阅频道，断线后由 PubSub 在下次接收时自动重连并重新订阅，用完需调用 Close
+func (r *Redis) Subscribe(channels ...string) (*PubSub, error) {
+	client, err := getClient(r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c := client.(type) {
+	case *redis.Client:
+		return c.Subscribe(channels...), nil
+	case *redis.ClusterClient:
+		return c.Subscribe(channels...), nil
+	default:
+		return nil, fmt.Errorf("redis 客户端 %T 不支持订阅", client)
+	}
+}
+
 // SAdd 添加一个或多个指定的member元素到集合的 key 中。
 func (r *Redis) SAdd(key string, values ...interface{}) (length int, err error) {
 	err = r.brk.DoWithAcceptable(func() error {
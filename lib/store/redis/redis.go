@@ -30,6 +30,12 @@ type (
 
 	Pipeliner = redis.Pipeliner
 
+	// 发布订阅相关类型
+	PubSub       = redis.PubSub
+	Message      = redis.Message
+	Subscription = redis.Subscription
+	Pong         = redis.Pong
+
 	Pair struct {
 		Key   string
 		Score int64
@@ -0,0 +1,586 @@
+package sqlx
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNoTable   = errors.New("未指定表名")
+	ErrNoColumns = errors.New("未指定列")
+)
+
+type (
+	// sqlBuilder 各语句构造器的公共部分：方言、where 条件和构造时的首个错误，插入语句不使用 where 条件
+	sqlBuilder struct {
+		dialect   dialect
+		wheres    []string
+		whereArgs []interface{}
+		err       error
+	}
+
+	// SelectBuilder 查询语句构造器
+	SelectBuilder struct {
+		sqlBuilder
+		columns    []string
+		table      string
+		joins      []string
+		joinArgs   []interface{}
+		groupBy    []string
+		having     []string
+		havingArgs []interface{}
+		orderBy    []string
+		limit      int
+		offset     int
+		forUpdate  bool
+	}
+
+	// InsertBuilder 插入语句构造器
+	InsertBuilder struct {
+		sqlBuilder
+		table   string
+		columns []string
+		rows    [][]interface{}
+		ignore  bool
+		updates []string
+	}
+
+	// UpdateBuilder 更新语句构造器
+	UpdateBuilder struct {
+		sqlBuilder
+		table   string
+		sets    []updateSet
+		setArgs []interface{}
+		limit   int
+
+		// 乐观锁的版本列及当前版本
+		versionColumn string
+		version       int64
+	}
+
+	// 更新语句中的一个赋值项，column 为空时为表达式
+	updateSet struct {
+		column string
+		expr   string
+	}
+
+	// DeleteBuilder 删除语句构造器
+	DeleteBuilder struct {
+		sqlBuilder
+		table string
+		limit int
+	}
+)
+
+// Select 新建查询语句构造器，未指定列时查询 *
+// 生成的语句使用 ? 占位符，可直接用于 Conn.Query，标识符默认按 MySQL 加引号
+func Select(columns ...string) *SelectBuilder {
+	return &SelectBuilder{
+		sqlBuilder: newSqlBuilder(),
+		columns:    columns,
+	}
+}
+
+// Insert 新建插入语句构造器
+func Insert(table string) *InsertBuilder {
+	return &InsertBuilder{
+		sqlBuilder: newSqlBuilder(),
+		table:      table,
+	}
+}
+
+// Update 新建更新语句构造器
+func Update(table string) *UpdateBuilder {
+	return &UpdateBuilder{
+		sqlBuilder: newSqlBuilder(),
+		table:      table,
+	}
+}
+
+// Delete 新建删除语句构造器
+func Delete(table string) *DeleteBuilder {
+	return &DeleteBuilder{
+		sqlBuilder: newSqlBuilder(),
+		table:      table,
+	}
+}
+
+// Columns 返回结构体 db 标记的列名列表，未标记的字段取字段名，db:"-" 的字段被忽略。
+// 标记了列名的嵌套结构体，其字段的列名与扫描时一致，形如 addr.city，
+// 以反引号包裹返回，生成语句时作为单个标识符按方言加引号，不会被当作表名.列名
+func Columns(model interface{}) []string {
+	t := reflect.TypeOf(model)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	info := getStructInfo(t)
+	columns := make([]string, len(info.fields))
+	for i, field := range info.fields {
+		if strings.IndexByte(field.column, '.') >= 0 {
+			columns[i] = "`" + field.column + "`"
+		} else if len(field.column) > 0 {
+			columns[i] = field.column
+		} else {
+			columns[i] = t.FieldByIndex(field.index).Name
+		}
+	}
+
+	return columns
+}
+
+// --------------- SelectBuilder ↓ --------------- //
+
+// For 按连接的数据库方言给标识符加引号
+func (b *SelectBuilder) For(c Conn) *SelectBuilder {
+	b.dialect = dialectOf(c)
+	return b
+}
+
+func (b *SelectBuilder) From(table string) *SelectBuilder {
+	b.table = table
+	return b
+}
+
+// Join 添加连接子句，如 Join("left join post p on p.user_id = u.id")
+func (b *SelectBuilder) Join(clause string, args ...interface{}) *SelectBuilder {
+	if clause, args, ok := b.expand(clause, args); ok {
+		b.joins = append(b.joins, clause)
+		b.joinArgs = append(b.joinArgs, args...)
+	}
+	return b
+}
+
+// Where 添加 and 条件，切片参数展开为 ?, ?, ?，如 Where("id in (?)", ids)
+func (b *SelectBuilder) Where(cond string, args ...interface{}) *SelectBuilder {
+	b.where(cond, args)
+	return b
+}
+
+func (b *SelectBuilder) GroupBy(columns ...string) *SelectBuilder {
+	b.groupBy = append(b.groupBy, columns...)
+	return b
+}
+
+// Having 添加分组后的 and 条件，切片参数同 Where 展开
+func (b *SelectBuilder) Having(cond string, args ...interface{}) *SelectBuilder {
+	if cond, args, ok := b.expand(cond, args); ok {
+		b.having = append(b.having, cond)
+		b.havingArgs = append(b.havingArgs, args...)
+	}
+	return b
+}
+
+// OrderBy 添加排序，如 OrderBy("created_at desc", "id")
+func (b *SelectBuilder) OrderBy(orders ...string) *SelectBuilder {
+	b.orderBy = append(b.orderBy, orders...)
+	return b
+}
+
+func (b *SelectBuilder) Limit(limit int) *SelectBuilder {
+	b.limit = limit
+	return b
+}
+
+// Offset 跳过的行数，MySQL 下未指定 Limit 时以最大行数作为 limit
+func (b *SelectBuilder) Offset(offset int) *SelectBuilder {
+	b.offset = offset
+	return b
+}
+
+// ForUpdate 加排他锁，需在事务中使用
+func (b *SelectBuilder) ForUpdate() *SelectBuilder {
+	b.forUpdate = true
+	return b
+}
+
+// ToSql 生成查询语句及参数
+func (b *SelectBuilder) ToSql() (string, []interface{}, error) {
+	if b.err != nil {
+		return "", nil, b.err
+	}
+	if len(b.table) == 0 {
+		return "", nil, ErrNoTable
+	}
+
+	var sb strings.Builder
+	sb.WriteString("select ")
+	if len(b.columns) == 0 {
+		sb.WriteString("*")
+	} else {
+		sb.WriteString(b.quoteAll(b.columns))
+	}
+	sb.WriteString(" from ")
+	sb.WriteString(b.dialect.quote(b.table))
+	for _, join := range b.joins {
+		sb.WriteString(" ")
+		sb.WriteString(join)
+	}
+	writeWhere(&sb, b.wheres)
+	if len(b.groupBy) > 0 {
+		sb.WriteString(" group by ")
+		sb.WriteString(b.quoteAll(b.groupBy))
+	}
+	if len(b.having) > 0 {
+		sb.WriteString(" having ")
+		sb.WriteString(joinConds(b.having))
+	}
+	if len(b.orderBy) > 0 {
+		orders := make([]string, len(b.orderBy))
+		for i, order := range b.orderBy {
+			orders[i] = b.quoteOrder(order)
+		}
+		sb.WriteString(" order by ")
+		sb.WriteString(strings.Join(orders, ", "))
+	}
+	sb.WriteString(b.dialect.limitOffset(b.limit, b.offset))
+	if b.forUpdate {
+		sb.WriteString(" for update")
+	}
+
+	var args []interface{}
+	args = append(args, b.joinArgs...)
+	args = append(args, b.whereArgs...)
+	args = append(args, b.havingArgs...)
+
+	return sb.String(), args, nil
+}
+
+// quoteOrder 给排序项中的列名加引号，如 name desc 转为 `name` desc
+func (b *SelectBuilder) quoteOrder(order string) string {
+	fields := strings.Fields(order)
+	switch {
+	case len(fields) == 1:
+		return b.dialect.quote(fields[0])
+	case len(fields) == 2 && (strings.EqualFold(fields[1], "asc") || strings.EqualFold(fields[1], "desc")):
+		return b.dialect.quote(fields[0]) + " " + fields[1]
+	default:
+		return order
+	}
+}
+
+// --------------- InsertBuilder ↓ --------------- //
+
+// For 按连接的数据库方言给标识符加引号
+func (b *InsertBuilder) For(c Conn) *InsertBuilder {
+	b.dialect = dialectOf(c)
+	return b
+}
+
+func (b *InsertBuilder) Columns(columns ...string) *InsertBuilder {
+	b.columns = append(b.columns, columns...)
+	return b
+}
+
+// Values 添加一行值，多次调用生成批量插入
+func (b *InsertBuilder) Values(values ...interface{}) *InsertBuilder {
+	if len(values) != len(b.columns) && b.err == nil {
+		b.err = fmt.Errorf("第 %d 行的值个数 %d 与列数 %d 不匹配", len(b.rows)+1, len(values), len(b.columns))
+	}
+	b.rows = append(b.rows, values)
+	return b
+}
+
+// Ignore 生成 insert ignore 语句，仅用于 MySQL
+func (b *InsertBuilder) Ignore() *InsertBuilder {
+	b.ignore = true
+	return b
+}
+
+// OnDuplicateKeyUpdate 唯一键冲突时用新值更新指定列，仅用于 MySQL
+func (b *InsertBuilder) OnDuplicateKeyUpdate(columns ...string) *InsertBuilder {
+	b.updates = append(b.updates, columns...)
+	return b
+}
+
+// ToSql 生成插入语句及参数
+func (b *InsertBuilder) ToSql() (string, []interface{}, error) {
+	if b.err != nil {
+		return "", nil, b.err
+	}
+	if len(b.table) == 0 {
+		return "", nil, ErrNoTable
+	}
+	if len(b.columns) == 0 || len(b.rows) == 0 {
+		return "", nil, ErrNoColumns
+	}
+
+	var sb strings.Builder
+	sb.WriteString("insert ")
+	if b.ignore {
+		sb.WriteString("ignore ")
+	}
+	sb.WriteString("into ")
+	sb.WriteString(b.dialect.quote(b.table))
+	sb.WriteString(" (")
+	sb.WriteString(b.quoteAll(b.columns))
+	sb.WriteString(") values ")
+
+	placeholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(b.columns)), ", ") + ")"
+	args := make([]interface{}, 0, len(b.columns)*len(b.rows))
+	for i, row := range b.rows {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(placeholders)
+		args = append(args, row...)
+	}
+
+	if len(b.updates) > 0 {
+		sb.WriteString(" on duplicate key update ")
+		for i, column := range b.updates {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			quoted := b.dialect.quote(column)
+			sb.WriteString(quoted)
+			sb.WriteString(" = values(")
+			sb.WriteString(quoted)
+			sb.WriteString(")")
+		}
+	}
+
+	return sb.String(), args, nil
+}
+
+// --------------- UpdateBuilder ↓ --------------- //
+
+// For 按连接的数据库方言给标识符加引号
+func (b *UpdateBuilder) For(c Conn) *UpdateBuilder {
+	b.dialect = dialectOf(c)
+	return b
+}
+
+// Set 设置列的新值
+func (b *UpdateBuilder) Set(column string, value interface{}) *UpdateBuilder {
+	b.sets = append(b.sets, updateSet{column: column})
+	b.setArgs = append(b.setArgs, value)
+	return b
+}
+
+// SetExpr 以表达式设置列值，如 SetExpr("count = count + ?", 1)
+func (b *UpdateBuilder) SetExpr(expr string, args ...interface{}) *UpdateBuilder {
+	b.sets = append(b.sets, updateSet{expr: expr})
+	b.setArgs = append(b.setArgs, args...)
+	return b
+}
+
+// SetMap 按列名顺序设置多列的新值
+func (b *UpdateBuilder) SetMap(values map[string]interface{}) *UpdateBuilder {
+	columns := make([]string, 0, len(values))
+	for column := range values {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	for _, column := range columns {
+		b.Set(column, values[column])
+	}
+	return b
+}
+
+// Where 添加 and 条件，切片参数展开为 ?, ?, ?，如 Where("id in (?)", ids)
+func (b *UpdateBuilder) Where(cond string, args ...interface{}) *UpdateBuilder {
+	b.where(cond, args)
+	return b
+}
+
+// WithVersion 乐观锁：递增版本列，并要求当前版本为 version，配合 ExecWithVersion 执行
+func (b *UpdateBuilder) WithVersion(column string, version int64) *UpdateBuilder {
+	b.versionColumn = column
+	b.version = version
+	return b
+}
+
+// Limit 限制更新行数，仅用于 MySQL
+func (b *UpdateBuilder) Limit(limit int) *UpdateBuilder {
+	b.limit = limit
+	return b
+}
+
+// ToSql 生成更新语句及参数
+func (b *UpdateBuilder) ToSql() (string, []interface{}, error) {
+	if b.err != nil {
+		return "", nil, b.err
+	}
+	if len(b.table) == 0 {
+		return "", nil, ErrNoTable
+	}
+	if len(b.sets) == 0 {
+		return "", nil, ErrNoColumns
+	}
+
+	var sb strings.Builder
+	sb.WriteString("update ")
+	sb.WriteString(b.dialect.quote(b.table))
+	sb.WriteString(" set ")
+	for i, set := range b.sets {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		if len(set.column) > 0 {
+			sb.WriteString(b.dialect.quote(set.column))
+			sb.WriteString(" = ?")
+		} else {
+			sb.WriteString(set.expr)
+		}
+	}
+
+	wheres, whereArgs := b.wheres, b.whereArgs
+	if len(b.versionColumn) > 0 {
+		quoted := b.dialect.quote(b.versionColumn)
+		sb.WriteString(", ")
+		sb.WriteString(quoted)
+		sb.WriteString(" = ")
+		sb.WriteString(quoted)
+		sb.WriteString(" + 1")
+		wheres = append(append([]string(nil), wheres...), quoted+" = ?")
+		whereArgs = append(append([]interface{}(nil), whereArgs...), b.version)
+	}
+	writeWhere(&sb, wheres)
+	if b.limit > 0 {
+		sb.WriteString(" limit ")
+		sb.WriteString(strconv.Itoa(b.limit))
+	}
+
+	args := append(append([]interface{}(nil), b.setArgs...), whereArgs...)
+	return sb.String(), args, nil
+}
+
+// --------------- DeleteBuilder ↓ --------------- //
+
+// For 按连接的数据库方言给标识符加引号
+func (b *DeleteBuilder) For(c Conn) *DeleteBuilder {
+	b.dialect = dialectOf(c)
+	return b
+}
+
+// Where 添加 and 条件，切片参数展开为 ?, ?, ?，如 Where("id in (?)", ids)
+func (b *DeleteBuilder) Where(cond string, args ...interface{}) *DeleteBuilder {
+	b.where(cond, args)
+	return b
+}
+
+// Limit 限制删除行数，仅用于 MySQL
+func (b *DeleteBuilder) Limit(limit int) *DeleteBuilder {
+	b.limit = limit
+	return b
+}
+
+// ToSql 生成删除语句及参数
+func (b *DeleteBuilder) ToSql() (string, []interface{}, error) {
+	if b.err != nil {
+		return "", nil, b.err
+	}
+	if len(b.table) == 0 {
+		return "", nil, ErrNoTable
+	}
+
+	var sb strings.Builder
+	sb.WriteString("delete from ")
+	sb.WriteString(b.dialect.quote(b.table))
+	writeWhere(&sb, b.wheres)
+	if b.limit > 0 {
+		sb.WriteString(" limit ")
+		sb.WriteString(strconv.Itoa(b.limit))
+	}
+
+	return sb.String(), b.whereArgs, nil
+}
+
+// --------------- 辅助方法 ↓ --------------- //
+
+func newSqlBuilder() sqlBuilder {
+	return sqlBuilder{
+		dialect: mysqlDialect{},
+	}
+}
+
+// where 添加 and 条件并展开切片参数
+func (b *sqlBuilder) where(cond string, args []interface{}) {
+	if cond, args, ok := b.expand(cond, args); ok {
+		b.wheres = append(b.wheres, cond)
+		b.whereArgs = append(b.whereArgs, args...)
+	}
+}
+
+// expand 展开子句中的切片参数，出错时记录首个错误并返回 false
+func (b *sqlBuilder) expand(clause string, args []interface{}) (string, []interface{}, bool) {
+	clause, args, err := expandArgs(clause, args)
+	if err != nil {
+		if b.err == nil {
+			b.err = err
+		}
+		return "", nil, false
+	}
+
+	return clause, args, true
+}
+
+func writeWhere(sb *strings.Builder, wheres []string) {
+	if len(wheres) > 0 {
+		sb.WriteString(" where ")
+		sb.WriteString(joinConds(wheres))
+	}
+}
+
+func (b *sqlBuilder) quoteAll(idents []string) string {
+	quoted := make([]string, len(idents))
+	for i, ident := range idents {
+		quoted[i] = b.dialect.quote(ident)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// joinConds 以 and 连接多个条件，多个条件时各自加括号以免 or 改变优先级
+func joinConds(conds []string) string {
+	if len(conds) == 1 {
+		return conds[0]
+	}
+
+	return "(" + strings.Join(conds, ") and (") + ")"
+}
+
+// expandArgs 将条件中对应切片参数的 ? 展开为 ?, ?, ?，引号内的 ? 及转义的 ?? 保持不变
+func expandArgs(cond string, args []interface{}) (string, []interface{}, error) {
+	var b strings.Builder
+	var expanded []interface{}
+	var argIdx int
+	for i := 0; i < len(cond); i++ {
+		ch := cond[i]
+		switch {
+		case ch == '\'' || ch == '"' || ch == '`':
+			end := skipQuoted(cond, i)
+			b.WriteString(cond[i:end])
+			i = end - 1
+		case ch == '?' && i+1 < len(cond) && cond[i+1] == '?':
+			// 由方言改写为 ? 本身
+			b.WriteString("??")
+			i++
+		case ch == '?':
+			if argIdx >= len(args) {
+				return "", nil, fmt.Errorf("参数个数少于问号个数: %q", cond)
+			}
+
+			placeholders, values, ok := expandValue(args[argIdx])
+			if !ok {
+				return "", nil, fmt.Errorf("第 %d 个参数为空切片: %q", argIdx+1, cond)
+			}
+			argIdx++
+			b.WriteString(placeholders)
+			expanded = append(expanded, values...)
+		default:
+			b.WriteByte(ch)
+		}
+	}
+
+	if argIdx < len(args) {
+		return "", nil, fmt.Errorf("参数个数多于问号个数: %q", cond)
+	}
+
+	return b.String(), expanded, nil
+}
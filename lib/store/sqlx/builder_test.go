@@ -0,0 +1,152 @@
+package sqlx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectBuilder(t *testing.T) {
+	query, args, err := Select("id", "u.name", "count(*) as total").
+		From("user u").
+		Join("left join post p on p.user_id = u.id and p.status = ?", 1).
+		Where("u.id in (?)", []int64{1, 2, 3}).
+		Where("name = ? or nickname = ?", "张三", "三").
+		GroupBy("id").
+		Having("total > ?", 10).
+		OrderBy("created_at desc", "id").
+		Limit(10).
+		Offset(20).
+		ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "select `id`, `u`.`name`, count(*) as total from user u "+
+		"left join post p on p.user_id = u.id and p.status = ? "+
+		"where (u.id in (?, ?, ?)) and (name = ? or nickname = ?) group by `id` having total > ? "+
+		"order by `created_at` desc, `id` limit 10 offset 20", query)
+	assert.Equal(t, []interface{}{1, int64(1), int64(2), int64(3), "张三", "三", 10}, args)
+
+	query, args, err = Select(Columns(&struct {
+		Id     int64  `db:"id"`
+		Name   string `db:"name"`
+		Secret string `db:"-"`
+	}{})...).From("user").For(NewPostgres("postgres://localhost/test")).Where("id = ?", 1).ForUpdate().ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, `select "id", "name" from "user" where id = ? for update`, query)
+	assert.Equal(t, []interface{}{1}, args)
+
+	// 连接和分组条件中的切片参数同样展开
+	query, args, err = Select("u.id").From("user u").
+		Join("join post p on p.user_id = u.id and p.status in (?)", []int{1, 2}).
+		GroupBy("u.id").
+		Having("count(*) in (?)", []int{3, 4}).
+		ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "select `u`.`id` from user u join post p on p.user_id = u.id and p.status in (?, ?) "+
+		"group by `u`.`id` having count(*) in (?, ?)", query)
+	assert.Equal(t, []interface{}{1, 2, 3, 4}, args)
+	_, _, err = Select().From("user").Having("total in (?)", []int{}).ToSql()
+	assert.NotNil(t, err)
+	_, _, err = Select().From("user").Join("join post p on p.id = ?").ToSql()
+	assert.NotNil(t, err)
+
+	// MySQL 不支持单独的 offset
+	query, _, err = Select().From("user").Offset(20).ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "select * from `user` limit 18446744073709551615 offset 20", query)
+	query, _, err = Select().From("user").For(NewPostgres("postgres://localhost/test")).Offset(20).ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, `select * from "user" offset 20`, query)
+
+	// 转义的 ?? 不占用参数，由方言改写
+	query, args, err = Select("id").From("user").Where("data ?? 'k' and id in (?)", []int{1, 2}).ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "select `id` from `user` where data ?? 'k' and id in (?, ?)", query)
+	assert.Equal(t, []interface{}{1, 2}, args)
+
+	_, _, err = Select().From("user").Where("id in (?)", []int{}).ToSql()
+	assert.NotNil(t, err)
+	_, _, err = Select().Where("id = ?").ToSql()
+	assert.NotNil(t, err)
+}
+
+func TestInsertBuilder(t *testing.T) {
+	query, args, err := Insert("user").Columns("id", "name").Values(1, "张三").Values(2, "李四").
+		OnDuplicateKeyUpdate("name").ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "insert into `user` (`id`, `name`) values (?, ?), (?, ?) "+
+		"on duplicate key update `name` = values(`name`)", query)
+	assert.Equal(t, []interface{}{1, "张三", 2, "李四"}, args)
+
+	query, _, err = Insert("user").Ignore().Columns("id").Values(1).ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "insert ignore into `user` (`id`) values (?)", query)
+
+	_, _, err = Insert("user").Columns("id", "name").Values(1).ToSql()
+	assert.NotNil(t, err)
+}
+
+func TestUpdateBuilder(t *testing.T) {
+	query, args, err := Update("user").
+		SetMap(map[string]interface{}{"name": "张三", "age": 18}).
+		SetExpr("version = version + ?", 1).
+		Where("id = ?", 1).
+		Limit(1).
+		ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "update `user` set `age` = ?, `name` = ?, version = version + ? where id = ? limit 1", query)
+	assert.Equal(t, []interface{}{18, "张三", 1, 1}, args)
+
+	_, _, err = Update("user").Where("id = ?", 1).ToSql()
+	assert.Equal(t, ErrNoColumns, err)
+}
+
+func TestDeleteBuilder(t *testing.T) {
+	query, args, err := Delete("user").Where("id in (?)", []string{"a", "b"}).Where("name <> '?'").ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "delete from `user` where (id in (?, ?)) and (name <> '?')", query)
+	assert.Equal(t, []interface{}{"a", "b"}, args)
+}
+
+func TestQuoteIdent(t *testing.T) {
+	assert.Equal(t, "`user`", quoteIdent("user", '`'))
+	assert.Equal(t, "`u`.*", quoteIdent("u.*", '`'))
+	assert.Equal(t, "*", quoteIdent("*", '`'))
+	assert.Equal(t, "`user`", quoteIdent("`user`", '`'))
+	assert.Equal(t, "max(id)", quoteIdent("max(id)", '`'))
+	assert.Equal(t, `"public"."user"`, quoteIdent("public.user", '"'))
+	assert.Equal(t, `"addr.city"`, quoteIdent("`addr.city`", '"'))
+	assert.Equal(t, "`u`.`name`", quoteIdent("`u`.`name`", '`'))
+}
+
+func TestColumns_Nested(t *testing.T) {
+	type address struct {
+		City string `db:"city"`
+	}
+	type user struct {
+		Id   int64   `db:"id"`
+		Addr address `db:"addr"`
+	}
+
+	// 嵌套列作为单个标识符，与扫描时的列名一致
+	columns := Columns(&user{})
+	assert.Equal(t, []string{"id", "`addr.city`"}, columns)
+
+	query, _, err := Select(columns...).From("user").ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "select `id`, `addr.city` from `user`", query)
+
+	query, _, err = Select(columns...).From("user").For(NewPostgres("postgres://localhost/test")).ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, `select "id", "addr.city" from "user"`, query)
+
+	query, _, err = Insert("user").Columns(columns...).Values(1, "上海").ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "insert into `user` (`id`, `addr.city`) values (?, ?)", query)
+}
+
+func TestUpdateBuilder_WithVersion(t *testing.T) {
+	query, args, err := Update("tag").Set("name", "张三").Where("id = ?", 1).WithVersion("version", 3).ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "update `tag` set `name` = ?, `version` = `version` + 1 where (id = ?) and (`version` = ?)", query)
+	assert.Equal(t, []interface{}{"张三", 1, int64(3)}, args)
+}
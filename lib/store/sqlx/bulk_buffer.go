@@ -0,0 +1,164 @@
+package sqlx
+
+import (
+	"errors"
+	"github.com/z-sdk/goa/lib/logx"
+	"sync"
+	"time"
+)
+
+const (
+	// FullBlock 缓冲已满时阻塞插入，直到有空位或插入器关闭
+	FullBlock FullPolicy = iota
+	// FullDrop 缓冲已满时丢弃新插入的行
+	FullDrop
+	// FullError 缓冲已满时返回 ErrBulkBufferFull
+	FullError
+
+	// 批量插入统计周期
+	bulkStatInterval = time.Minute
+)
+
+var (
+	ErrBulkBufferFull     = errors.New("批量插入缓冲已满")
+	ErrBulkInserterClosed = errors.New("批量插入器已关闭")
+	errBulkRowDropped     = errors.New("批量插入缓冲已满，丢弃该行")
+)
+
+type (
+	// FullPolicy 批量插入缓冲已满时的处理策略
+	FullPolicy int
+
+	// BulkStats 批量插入器的统计信息
+	BulkStats struct {
+		Queued  int    // 已接收但尚未执行完成的行数
+		Dropped uint64 // 因缓冲已满而丢弃的行数
+	}
+
+	// bulkBuffer 限制已接收但未执行完成的行数，防止数据库变慢时内存无限增长
+	bulkBuffer struct {
+		lock    sync.Mutex
+		cond    *sync.Cond
+		name    string
+		size    int
+		policy  FullPolicy
+		queued  int
+		adding  int // 已占用缓冲位置但尚未加入调度器的行数
+		dropped uint64
+		closed  bool
+
+		lastStat    time.Time // 上次输出统计的时间
+		statDropped uint64    // 上次输出统计时的丢弃行数
+	}
+)
+
+func newBulkBuffer(name string, size int, policy FullPolicy) *bulkBuffer {
+	buf := &bulkBuffer{
+		name:     name,
+		size:     size,
+		policy:   policy,
+		lastStat: time.Now(),
+	}
+	buf.cond = sync.NewCond(&buf.lock)
+	return buf
+}
+
+// acquire 占用一个缓冲位置，缓冲已满时按策略处理
+func (b *bulkBuffer) acquire() error {
+	b.lock.Lock()
+	err := b.doAcquire()
+	stat, ok := b.statIfDue()
+	b.lock.Unlock()
+
+	if ok {
+		b.logStat(stat)
+	}
+	return err
+}
+
+func (b *bulkBuffer) doAcquire() error {
+	for {
+		if b.closed {
+			return ErrBulkInserterClosed
+		}
+		if b.size <= 0 || b.queued < b.size {
+			break
+		}
+
+		switch b.policy {
+		case FullDrop:
+			b.dropped++
+			return errBulkRowDropped
+		case FullError:
+			return ErrBulkBufferFull
+		default:
+			b.cond.Wait()
+		}
+	}
+
+	b.queued++
+	b.adding++
+	return nil
+}
+
+// added 占用的缓冲位置已加入调度器，须在 acquire 成功后调用
+func (b *bulkBuffer) added() {
+	b.lock.Lock()
+	b.adding--
+	b.lock.Unlock()
+	b.cond.Broadcast()
+}
+
+// release 释放 n 个缓冲位置
+func (b *bulkBuffer) release(n int) {
+	b.lock.Lock()
+	b.queued -= n
+	stat, ok := b.statIfDue()
+	b.lock.Unlock()
+	b.cond.Broadcast()
+
+	if ok {
+		b.logStat(stat)
+	}
+}
+
+// close 关闭缓冲，唤醒所有等待中的插入，并等待已占用缓冲位置的行加入调度器
+func (b *bulkBuffer) close() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.closed = true
+	b.cond.Broadcast()
+	for b.adding > 0 {
+		b.cond.Wait()
+	}
+}
+
+// statIfDue 距上次输出超过统计周期时返回待输出的统计，Dropped 为本周期内丢弃的行数，须持有锁调用
+func (b *bulkBuffer) statIfDue() (BulkStats, bool) {
+	now := time.Now()
+	if now.Sub(b.lastStat) < bulkStatInterval {
+		return BulkStats{}, false
+	}
+
+	stat := BulkStats{
+		Queued:  b.queued,
+		Dropped: b.dropped - b.statDropped,
+	}
+	b.lastStat = now
+	b.statDropped = b.dropped
+	return stat, stat.Queued > 0 || stat.Dropped > 0
+}
+
+func (b *bulkBuffer) logStat(stat BulkStats) {
+	logx.Statf("bulkinsert(%s) - queued: %d, dropped: %d", b.name, stat.Queued, stat.Dropped)
+}
+
+func (b *bulkBuffer) stats() BulkStats {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return BulkStats{
+		Queued:  b.queued,
+		Dropped: b.dropped,
+	}
+}
@@ -1,24 +1,27 @@
 package sqlx
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"github.com/z-sdk/goa/lib/dispatcher"
 	"github.com/z-sdk/goa/lib/logx"
+	"github.com/z-sdk/goa/lib/proc"
 	"github.com/z-sdk/goa/lib/stringx"
 	"strings"
+	"sync"
 	"time"
 )
 
 const (
-	// 最大批量插入数量
-	maxBulkRows = 1000
+	// 默认最大批量插入行数
+	defaultBulkRows = 1000
 
 	// SQL 中的 values 标记
 	valuesTag = "values"
 
-	// 定期执行程序的的间隔执行时间
-	flushInterval = time.Second
+	// 默认定期执行程序的间隔执行时间
+	defaultFlushInterval = time.Second
 )
 
 var emptyBulkStmt bulkStmt
@@ -34,6 +37,15 @@ type (
 
 		// 定时调度器
 		dispatcher *dispatcher.PeriodicalDispatcher
+
+		// 待插入行的缓冲
+		buffer *bulkBuffer
+
+		// 移除程序关闭监听器
+		removeListener func()
+
+		// 确保只关闭一次
+		closeOnce sync.Once
 	}
 
 	// 批量插入语句结构
@@ -45,7 +57,7 @@ type (
 		// values 之后小括号内的值格式
 		valueFormat string
 
-		// valueFormat 值格式之后的剩余字符串
+		// valueFormat 值格式之后的剩余字符串，如 on duplicate key update ...
 		suffix string
 	}
 
@@ -53,40 +65,151 @@ type (
 	insertManager struct {
 		conn          Conn
 		stmt          bulkStmt
-		values        []string
+		rows          []bulkRow
+		bytes         int
+		ready         []bulkRow // 因加入新行将超出限制而待执行的一批
+		maxRows       int
+		maxBytes      int
+		buffer        *bulkBuffer
 		resultHandler ResultHandler
+		batchHandler  BatchHandler
+	}
+
+	// 待插入的一行数据
+	bulkRow struct {
+		value string        // 格式化后的值字符串
+		args  []interface{} // 原始参数值
+	}
+
+	// BatchResult 一批数据的插入结果
+	BatchResult struct {
+		Result   sql.Result      // 执行结果，出错时可能为 nil
+		Rows     int             // 本批行数
+		Duration time.Duration   // 执行耗时
+		Err      error           // 执行错误
+		Values   [][]interface{} // 本批每行的原始参数值，可用于失败数据的重试或转存
 	}
 
-	// 执行结果处理器
+	// 执行结果处理器，每批插入执行后调用
 	ResultHandler func(sql.Result, error)
+
+	// 批次结果处理器，每批插入执行后调用，可拿到本批的行数、耗时和原始参数值
+	BatchHandler func(result BatchResult)
+
+	// 批量插入可选项
+	bulkOptions struct {
+		maxRows       int
+		maxBytes      int
+		flushInterval time.Duration
+		bufferSize    int
+		fullPolicy    FullPolicy
+	}
+
+	// BulkOption 批量插入器的可选配置函数
+	BulkOption func(o *bulkOptions)
 )
 
 // NewBulkInserter 新建批量插入器
-func NewBulkInserter(c Conn, stmt string) (*BulkInserter, error) {
+func NewBulkInserter(c Conn, stmt string, opts ...BulkOption) (*BulkInserter, error) {
 	insertStmt, err := parseBulkInsertStmt(stmt)
 	if err != nil {
 		return nil, err
 	}
 
-	manager := &insertManager{
-		conn: c,
-		stmt: insertStmt,
+	options := bulkOptions{
+		maxRows:       defaultBulkRows,
+		flushInterval: defaultFlushInterval,
+	}
+	for _, opt := range opts {
+		opt(&options)
 	}
 
-	return &BulkInserter{
+	buffer := newBulkBuffer(bulkTableName(insertStmt.prefix), options.bufferSize, options.fullPolicy)
+	manager := &insertManager{
+		conn:     c,
+		stmt:     insertStmt,
+		maxRows:  options.maxRows,
+		maxBytes: options.maxBytes,
+		buffer:   buffer,
+	}
+	bi := &BulkInserter{
 		stmt:       insertStmt,
 		manager:    manager,
-		dispatcher: dispatcher.NewPeriodicalDispatcher(flushInterval, manager),
-	}, nil
+		dispatcher: dispatcher.NewPeriodicalDispatcher(options.flushInterval, manager),
+		buffer:     buffer,
+	}
+
+	// 程序关闭前，插入剩余数据并等待执行完成，插入器关闭时移除监听器
+	bi.removeListener = proc.AddRemovableShutdownListener(bi.Close)
+
+	return bi, nil
 }
 
+// WithBulkRows 设置每批最大插入行数，默认 1000 行
+func WithBulkRows(rows int) BulkOption {
+	return func(o *bulkOptions) {
+		if rows > 0 {
+			o.maxRows = rows
+		}
+	}
+}
+
+// WithBulkBytes 设置每批语句的最大字节数，达到后立即执行插入，默认不限制。
+// 应小于数据库允许的最大包大小，如 MySQL 的 max_allowed_packet
+func WithBulkBytes(bytes int) BulkOption {
+	return func(o *bulkOptions) {
+		if bytes > 0 {
+			o.maxBytes = bytes
+		}
+	}
+}
+
+// WithFlushInterval 设置定期执行插入的间隔时间，默认 1 秒
+func WithFlushInterval(interval time.Duration) BulkOption {
+	return func(o *bulkOptions) {
+		if interval > 0 {
+			o.flushInterval = interval
+		}
+	}
+}
+
+// WithBufferSize 设置已接收但尚未执行完成的最大行数，默认不限制。
+// 缓冲已满时按 WithFullPolicy 设置的策略处理
+func WithBufferSize(size int) BulkOption {
+	return func(o *bulkOptions) {
+		if size > 0 {
+			o.bufferSize = size
+		}
+	}
+}
+
+// WithFullPolicy 设置缓冲已满时的处理策略，默认 FullBlock
+func WithFullPolicy(policy FullPolicy) BulkOption {
+	return func(o *bulkOptions) {
+		o.fullPolicy = policy
+	}
+}
+
+// Insert 插入一行数据，插入器关闭后返回 ErrBulkInserterClosed，
+// 缓冲已满时按策略阻塞、丢弃（返回 nil）或返回 ErrBulkBufferFull
 func (bi *BulkInserter) Insert(args ...interface{}) error {
-	value, err := formatQuery(bi.stmt.valueFormat, args...)
+	value, err := formatDialectQuery(dialectOf(bi.manager.conn), bi.stmt.valueFormat, args...)
 	if err != nil {
 		return err
 	}
 
-	bi.dispatcher.Add(value)
+	if err := bi.buffer.acquire(); err == errBulkRowDropped {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// 关闭插入器时须等待已占用缓冲位置的行加入调度器，以免在 Wait 之后加入而无人执行
+	defer bi.buffer.added()
+	bi.dispatcher.Add(bulkRow{
+		value: value,
+		args:  append([]interface{}(nil), args...),
+	})
 
 	return nil
 }
@@ -95,13 +218,34 @@ func (bi *BulkInserter) Flush() {
 	bi.dispatcher.Flush()
 }
 
-// SetResultHandler 设置结果处理器
+// Close 关闭插入器，插入剩余数据并等待所有插入执行完成，可重复调用
+func (bi *BulkInserter) Close() {
+	bi.closeOnce.Do(func() {
+		bi.removeListener()
+		bi.buffer.close()
+		bi.dispatcher.Wait()
+	})
+}
+
+// Stats 返回插入器的统计信息，插入器同时按统计周期将其输出到统计日志
+func (bi *BulkInserter) Stats() BulkStats {
+	return bi.buffer.stats()
+}
+
+// SetRequestHandler 设置结果处理器
 func (bi *BulkInserter) SetRequestHandler(handler ResultHandler) {
 	bi.dispatcher.Sync(func() {
 		bi.manager.resultHandler = handler
 	})
 }
 
+// SetBatchHandler 设置批次结果处理器，与 SetRequestHandler 设置的结果处理器可同时使用
+func (bi *BulkInserter) SetBatchHandler(handler BatchHandler) {
+	bi.dispatcher.Sync(func() {
+		bi.manager.batchHandler = handler
+	})
+}
+
 func (bi *BulkInserter) UpdateStmt(stmt string) error {
 	newStmt, err := parseBulkInsertStmt(stmt)
 	if err != nil {
@@ -124,16 +268,37 @@ func (bi *BulkInserter) UpdateOrDelete(fn func()) {
 // --------------- 扩展 insertManager ↓ --------------- //
 
 func (m *insertManager) Add(row interface{}) bool {
-	m.values = append(m.values, row.(string))
-	return len(m.values) >= maxBulkRows
+	r := row.(bulkRow)
+	// 值之间的逗号分隔符
+	size := len(r.value) + 1
+	// 加入新行将超出限制时，先执行已有的行，新行留到下一批，因此只有单行就超出字节数限制时语句才会超出
+	if len(m.rows) > 0 && (len(m.rows) >= m.maxRows || m.maxBytes > 0 && m.stmtBytes(m.bytes+size) > m.maxBytes) {
+		m.ready = m.rows
+		m.rows = []bulkRow{r}
+		m.bytes = size
+		return true
+	}
+
+	m.rows = append(m.rows, r)
+	m.bytes += size
+	if len(m.rows) >= m.maxRows {
+		return true
+	}
+
+	return m.maxBytes > 0 && m.stmtBytes(m.bytes) >= m.maxBytes
 }
 
 func (m *insertManager) Execute(rows interface{}) {
-	values := rows.([]string)
-	if len(values) == 0 {
+	bulkRows := rows.([]bulkRow)
+	if len(bulkRows) == 0 {
 		return
 	}
+	defer m.buffer.release(len(bulkRows))
 
+	values := make([]string, len(bulkRows))
+	for i, row := range bulkRows {
+		values[i] = row.value
+	}
 	stmtWithoutValues := m.stmt.prefix
 	valuesStr := strings.Join(values, ",")
 	stmt := strings.Join([]string{stmtWithoutValues, valuesStr}, " ")
@@ -142,20 +307,53 @@ func (m *insertManager) Execute(rows interface{}) {
 	}
 
 	// 真正执行插入
-	result, err := m.conn.Exec(stmt)
+	startTime := time.Now()
+	result, err := m.conn.ExecCtx(withMethod(context.Background(), MethodBulkInsert), stmt)
+	duration := time.Since(startTime)
 
 	// 处理执行结果
+	if m.batchHandler != nil {
+		args := make([][]interface{}, len(bulkRows))
+		for i, row := range bulkRows {
+			args[i] = row.args
+		}
+		m.batchHandler(BatchResult{
+			Result:   result,
+			Rows:     len(bulkRows),
+			Duration: duration,
+			Err:      err,
+			Values:   args,
+		})
+	}
 	if m.resultHandler != nil {
 		m.resultHandler(result, err)
-	} else if err != nil {
+	} else if m.batchHandler == nil && err != nil {
 		logx.Errorf("[批量插入] SQL: %s, 错误: %s", stmt, err)
 	}
 }
 
+// PopAll 调度器在 Add 返回 true 后立即调用，此时只返回待执行的一批，新行留到下一批
 func (m *insertManager) PopAll() interface{} {
-	values := m.values
-	m.values = nil
-	return values
+	if m.ready != nil {
+		rows := m.ready
+		m.ready = nil
+		return rows
+	}
+
+	rows := m.rows
+	m.rows = nil
+	m.bytes = 0
+	return rows
+}
+
+// stmtBytes 返回值部分（含分隔符）为 valueBytes 字节时整条语句的字节数
+func (m *insertManager) stmtBytes(valueBytes int) int {
+	size := len(m.stmt.prefix) + valueBytes
+	if len(m.stmt.suffix) > 0 {
+		size += len(m.stmt.suffix) + 1
+	}
+
+	return size
 }
 
 // --------------- 辅助方法 ↓ --------------- //
@@ -168,9 +366,12 @@ func parseBulkInsertStmt(stmt string) (bulkStmt, error) {
 	// insert into users values
 	// (1, "张三")
 	// (2, "李四")
+	//
+	// insert ignore into users(id, name) values (?, ?)
+	// insert into users(id, name) values (?, ?) on duplicate key update name = values(name)
 
 	lowerStmt := strings.ToLower(stmt)
-	valuesPos := strings.Index(lowerStmt, valuesTag)
+	valuesPos := indexValuesTag(lowerStmt)
 	if valuesPos <= 0 {
 		return emptyBulkStmt, fmt.Errorf("command 中没有找到 values 标记：%q", stmt)
 	}
@@ -195,31 +396,34 @@ func parseBulkInsertStmt(stmt string) (bulkStmt, error) {
 		}
 	}
 
-	// 尝试找出 values 之后插入的值格式字符串
-	var numArgs int
+	// 尝试找出 values 之后插入的值格式字符串，值中可能包含函数调用，如 (?, now())
+	var numArgs, numValues int
 	var valueFormat string
 	var suffix string
 	left := strings.IndexByte(lowerStmt[valuesPos:], '(')
 	if left > 0 {
-		right = strings.IndexByte(lowerStmt[valuesPos+left:], ')')
+		right = matchParen(lowerStmt, valuesPos+left)
 		if right > 0 {
-			values := lowerStmt[valuesPos+left : valuesPos+left+right]
-			for _, x := range values {
+			for _, x := range lowerStmt[valuesPos+left : right] {
 				if x == '?' {
 					numArgs++
 				}
 			}
-			valueFormat = stmt[valuesPos+left : valuesPos+left+right+1]
-			suffix = strings.TrimSpace(stmt[valuesPos+left+right+1:])
+			valueFormat = stmt[valuesPos+left : right+1]
+			numValues = countValues(valueFormat)
+			suffix = strings.TrimSpace(stmt[right+1:])
 		}
 	}
 
 	if numArgs == 0 {
 		return emptyBulkStmt, fmt.Errorf("没有变量占位符: %q", stmt)
 	}
-	if numCols > 0 && numCols != numArgs {
+	if numCols > 0 && numCols != numValues {
 		return emptyBulkStmt, fmt.Errorf("列数和参数值个数不匹配: %q", stmt)
 	}
+	if strings.IndexByte(suffix, '?') >= 0 {
+		return emptyBulkStmt, fmt.Errorf("values 之后的语句不支持变量占位符: %q", stmt)
+	}
 
 	return bulkStmt{
 		prefix:      stmt[:valuesPos+len(valuesTag)],
@@ -227,3 +431,105 @@ func parseBulkInsertStmt(stmt string) (bulkStmt, error) {
 		suffix:      suffix,
 	}, nil
 }
+
+// indexValuesTag 返回独立的 values 关键字位置，忽略表名、列名中包含的 values
+func indexValuesTag(lowerStmt string) int {
+	var offset int
+	for {
+		pos := strings.Index(lowerStmt[offset:], valuesTag)
+		if pos < 0 {
+			return -1
+		}
+
+		pos += offset
+		end := pos + len(valuesTag)
+		if (pos == 0 || !isNameByte(lowerStmt[pos-1])) && (end == len(lowerStmt) || !isNameByte(lowerStmt[end])) {
+			return pos
+		}
+		offset = end
+	}
+}
+
+// matchParen 返回与 left 位置左括号匹配的右括号位置，忽略引号内的括号，找不到返回 -1
+func matchParen(stmt string, left int) int {
+	var depth int
+	var quote byte
+	for i := left; i < len(stmt); i++ {
+		ch := stmt[i]
+		if quote != 0 {
+			if ch == '\\' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch ch {
+		case '\'', '"', '`':
+			quote = ch
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// countValues 返回值格式中的值个数，即最外层括号内逗号分隔的项数
+func countValues(valueFormat string) int {
+	count := 1
+	var depth int
+	var quote byte
+	for i := 0; i < len(valueFormat); i++ {
+		ch := valueFormat[i]
+		if quote != 0 {
+			if ch == '\\' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch ch {
+		case '\'', '"', '`':
+			quote = ch
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 1 {
+				count++
+			}
+		}
+	}
+
+	return count
+}
+
+// bulkTableName 返回批量插入语句前缀中的表名，用于统计输出
+func bulkTableName(prefix string) string {
+	fields := strings.Fields(prefix)
+	for i, field := range fields {
+		if strings.EqualFold(field, "into") && i+1 < len(fields) {
+			name := fields[i+1]
+			if pos := strings.IndexByte(name, '('); pos >= 0 {
+				name = name[:pos]
+			}
+			return name
+		}
+	}
+
+	return prefix
+}
+
+func isNameByte(ch byte) bool {
+	return ch == '_' || ch == '`' || ch == '.' || '0' <= ch && ch <= '9' || 'a' <= ch && ch <= 'z'
+}
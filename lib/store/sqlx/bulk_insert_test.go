@@ -1,11 +1,16 @@
 package sqlx
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 type mockedConn struct {
@@ -17,6 +22,38 @@ func (c *mockedConn) Query(dest interface{}, query string, args ...interface{})
 	panic("implement me")
 }
 
+func (c *mockedConn) QueryCtx(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	panic("implement me")
+}
+
+func (c *mockedConn) QueryStream(dest interface{}, fn StreamFn, query string, args ...interface{}) error {
+	panic("implement me")
+}
+
+func (c *mockedConn) QueryStreamCtx(ctx context.Context, dest interface{}, fn StreamFn, query string,
+	args ...interface{}) error {
+	panic("implement me")
+}
+
+func (c *mockedConn) QueryPage(dest interface{}, query string, page, size int, args ...interface{}) (int64, error) {
+	panic("implement me")
+}
+
+func (c *mockedConn) QueryPageCtx(ctx context.Context, dest interface{}, query string, page, size int,
+	args ...interface{}) (int64, error) {
+	panic("implement me")
+}
+
+func (c *mockedConn) QueryAfter(dest interface{}, query, cursorColumn, cursor string, size int,
+	args ...interface{}) (string, error) {
+	panic("implement me")
+}
+
+func (c *mockedConn) QueryAfterCtx(ctx context.Context, dest interface{}, query, cursorColumn, cursor string,
+	size int, args ...interface{}) (string, error) {
+	panic("implement me")
+}
+
 func (c *mockedConn) Exec(query string, args ...interface{}) (sql.Result, error) {
 	c.query = query
 	c.args = args
@@ -24,10 +61,70 @@ func (c *mockedConn) Exec(query string, args ...interface{}) (sql.Result, error)
 	return nil, nil
 }
 
+func (c *mockedConn) ExecCtx(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return c.Exec(query, args...)
+}
+
+func (c *mockedConn) QueryNamed(dest interface{}, query string, arg interface{}) error {
+	panic("implement me")
+}
+
+func (c *mockedConn) QueryNamedCtx(ctx context.Context, dest interface{}, query string, arg interface{}) error {
+	panic("implement me")
+}
+
+func (c *mockedConn) ExecNamed(query string, arg interface{}) (sql.Result, error) {
+	panic("implement me")
+}
+
+func (c *mockedConn) ExecNamedCtx(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
+	panic("implement me")
+}
+
 func (c *mockedConn) Transact(fn TransactFn) error {
 	panic("implement me")
 }
 
+func (c *mockedConn) TransactCtx(ctx context.Context, fn TransactFn) error {
+	panic("implement me")
+}
+
+func (c *mockedConn) TransactWithOptions(opts TxOptions, fn TransactFn) error {
+	panic("implement me")
+}
+
+func (c *mockedConn) TransactWithOptionsCtx(ctx context.Context, opts TxOptions, fn TransactFn) error {
+	panic("implement me")
+}
+
+func (c *mockedConn) Prepare(query string) (StmtSession, error) {
+	panic("implement me")
+}
+
+func (c *mockedConn) PoolStats() sql.DBStats {
+	return sql.DBStats{}
+}
+
+// recordingConn 并发安全地记录所有执行的语句
+type recordingConn struct {
+	mockedConn
+	lock  sync.Mutex
+	stmts []string
+}
+
+func (c *recordingConn) ExecCtx(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.stmts = append(c.stmts, query)
+	return nil, nil
+}
+
+func (c *recordingConn) statements() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]string(nil), c.stmts...)
+}
+
 func TestBulkInserter_Insert(t *testing.T) {
 	runSqlTest(t, func(conn Conn) {
 		//var conn mockedConn
@@ -94,3 +191,209 @@ func runSqlTest(t *testing.T, fn func(db Conn)) {
 	//	t.Errorf("存在为满足异常: %s", err)
 	//}
 }
+
+func TestParseBulkInsertStmt(t *testing.T) {
+	stmt, err := parseBulkInsertStmt(`insert ignore into user_values(id, name, created_at) values (?, ?, now())`)
+	assert.Nil(t, err)
+	assert.Equal(t, "insert ignore into user_values(id, name, created_at) values", stmt.prefix)
+	assert.Equal(t, "(?, ?, now())", stmt.valueFormat)
+	assert.Equal(t, "", stmt.suffix)
+
+	stmt, err = parseBulkInsertStmt(`INSERT INTO user(id, name) VALUES(?, ?) ON DUPLICATE KEY UPDATE name=VALUES(name)`)
+	assert.Nil(t, err)
+	assert.Equal(t, "INSERT INTO user(id, name) VALUES", stmt.prefix)
+	assert.Equal(t, "(?, ?)", stmt.valueFormat)
+	assert.Equal(t, "ON DUPLICATE KEY UPDATE name=VALUES(name)", stmt.suffix)
+
+	_, err = parseBulkInsertStmt(`insert into user(id, name) values (?, ?) on duplicate key update name = ?`)
+	assert.NotNil(t, err)
+	_, err = parseBulkInsertStmt(`insert into user(id, name) values (?, ?, ?)`)
+	assert.NotNil(t, err)
+}
+
+func TestBulkInserter_Batch(t *testing.T) {
+	var conn mockedConn
+	inserter, err := NewBulkInserter(&conn, `insert into user(id, name) values (?, ?)`, WithBulkRows(2),
+		WithFlushInterval(time.Hour))
+	assert.Nil(t, err)
+
+	var results []BatchResult
+	inserter.SetBatchHandler(func(result BatchResult) {
+		results = append(results, result)
+	})
+	var errs []error
+	inserter.SetRequestHandler(func(result sql.Result, err error) {
+		errs = append(errs, err)
+	})
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, inserter.Insert(i, "user_"+strconv.Itoa(i)))
+	}
+	inserter.Close()
+
+	assert.Equal(t, "insert into user(id, name) values (2, 'user_2')", conn.query)
+	assert.Equal(t, 2, len(results))
+	assert.Equal(t, 2, results[0].Rows)
+	assert.Equal(t, [][]interface{}{{0, "user_0"}, {1, "user_1"}}, results[0].Values)
+	assert.Equal(t, [][]interface{}{{2, "user_2"}}, results[1].Values)
+	assert.Equal(t, []error{nil, nil}, errs)
+
+	// 达到字节数限制立即执行
+	const stmt = "insert into user(id, name) values (1, '张三'),(2, '李四')"
+	var recorder recordingConn
+	inserter, err = NewBulkInserter(&recorder, `insert into user(id, name) values (?, ?)`, WithBulkBytes(len(stmt)),
+		WithFlushInterval(time.Hour))
+	assert.Nil(t, err)
+	assert.Nil(t, inserter.Insert(1, "张三"))
+	assert.Nil(t, inserter.Insert(2, "李四"))
+	assert.Eventually(t, func() bool {
+		return len(recorder.statements()) == 1
+	}, time.Second, 10*time.Millisecond)
+	inserter.Close()
+	assert.Equal(t, []string{stmt}, recorder.statements())
+}
+
+func TestBulkInserter_Bytes(t *testing.T) {
+	const maxBytes = 60
+	var conn recordingConn
+	inserter, err := NewBulkInserter(&conn, `insert into user(id, name) values (?, ?)`, WithBulkBytes(maxBytes),
+		WithFlushInterval(time.Hour))
+	assert.Nil(t, err)
+
+	long := strings.Repeat("x", maxBytes)
+	names := []string{"张三", "李四", "王五", "kevin", long, "赵六", "钱七", "孙八", "周九"}
+	for i, name := range names {
+		assert.Nil(t, inserter.Insert(i, name))
+	}
+	inserter.Close()
+
+	var rows int
+	for _, stmt := range conn.statements() {
+		rows += strings.Count(stmt, "'") / 2
+		if strings.Contains(stmt, long) {
+			// 单行就超出字节数限制时单独执行
+			assert.Equal(t, fmt.Sprintf("insert into user(id, name) values (4, '%s')", long), stmt)
+			continue
+		}
+		assert.True(t, len(stmt) <= maxBytes, stmt)
+	}
+	assert.Equal(t, len(names), rows)
+}
+
+func TestBulkInserter_Buffer(t *testing.T) {
+	var conn mockedConn
+	const stmt = `insert into user(id, name) values (?, ?)`
+
+	// 缓冲已满时返回错误
+	inserter, err := NewBulkInserter(&conn, stmt, WithBufferSize(2), WithFullPolicy(FullError),
+		WithFlushInterval(time.Hour))
+	assert.Nil(t, err)
+	assert.Nil(t, inserter.Insert(1, "张三"))
+	assert.Nil(t, inserter.Insert(2, "李四"))
+	assert.Equal(t, ErrBulkBufferFull, inserter.Insert(3, "王五"))
+	assert.Equal(t, BulkStats{Queued: 2}, inserter.Stats())
+	inserter.Flush()
+	assert.Equal(t, BulkStats{}, inserter.Stats())
+
+	// 缓冲已满时丢弃
+	inserter, err = NewBulkInserter(&conn, stmt, WithBufferSize(1), WithFullPolicy(FullDrop),
+		WithFlushInterval(time.Hour))
+	assert.Nil(t, err)
+	assert.Nil(t, inserter.Insert(1, "张三"))
+	assert.Nil(t, inserter.Insert(2, "李四"))
+	assert.Equal(t, BulkStats{Queued: 1, Dropped: 1}, inserter.Stats())
+
+	// 关闭时插入剩余数据，之后不再接收
+	inserter.Close()
+	assert.Equal(t, "insert into user(id, name) values (1, '张三')", conn.query)
+	assert.Equal(t, ErrBulkInserterClosed, inserter.Insert(3, "王五"))
+	inserter.Close()
+
+	// 缓冲已满时阻塞，直到有空位
+	inserter, err = NewBulkInserter(&conn, stmt, WithBufferSize(1), WithFlushInterval(time.Hour))
+	assert.Nil(t, err)
+	assert.Nil(t, inserter.Insert(1, "张三"))
+	done := make(chan error)
+	go func() {
+		done <- inserter.Insert(2, "李四")
+	}()
+	select {
+	case <-done:
+		t.Fatal("缓冲已满时应阻塞")
+	case <-time.After(50 * time.Millisecond):
+	}
+	inserter.Flush()
+	assert.Nil(t, <-done)
+	inserter.Close()
+	assert.Equal(t, "insert into user(id, name) values (2, '李四')", conn.query)
+}
+
+func TestBulkInserter_InsertClose(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		var conn recordingConn
+		inserter, err := NewBulkInserter(&conn, `insert into user(id, name) values (?, ?)`, WithBulkRows(3),
+			WithFlushInterval(time.Hour))
+		assert.Nil(t, err)
+
+		var inserted int64
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		for j := 0; j < 50; j++ {
+			wg.Add(1)
+			go func(id int) {
+				defer wg.Done()
+				<-start
+				for k := 0; k < 20; k++ {
+					if err := inserter.Insert(id, "user"); err == nil {
+						atomic.AddInt64(&inserted, 1)
+					} else {
+						assert.Equal(t, ErrBulkInserterClosed, err)
+					}
+				}
+			}(j)
+		}
+		close(start)
+		time.Sleep(time.Millisecond)
+		inserter.Close()
+		wg.Wait()
+
+		// Close 返回前成功插入的行都已执行
+		var rows int64
+		for _, stmt := range conn.statements() {
+			rows += int64(strings.Count(stmt, "("))
+		}
+		// 每条语句的 user(id, name) 也含一个左括号
+		rows -= int64(len(conn.statements()))
+		assert.Equal(t, atomic.LoadInt64(&inserted), rows)
+		assert.Equal(t, BulkStats{}, inserter.Stats())
+	}
+}
+
+func TestBulkBuffer_StatIfDue(t *testing.T) {
+	buf := newBulkBuffer("user", 1, FullDrop)
+	assert.Nil(t, buf.acquire())
+	buf.added()
+	assert.Equal(t, errBulkRowDropped, buf.acquire())
+	assert.Equal(t, errBulkRowDropped, buf.acquire())
+
+	// 未到统计周期不输出
+	_, ok := buf.statIfDue()
+	assert.False(t, ok)
+
+	buf.lastStat = time.Now().Add(-bulkStatInterval)
+	stat, ok := buf.statIfDue()
+	assert.True(t, ok)
+	assert.Equal(t, BulkStats{Queued: 1, Dropped: 2}, stat)
+
+	// 输出本周期内丢弃的行数，没有积压和丢弃时不输出
+	buf.release(1)
+	buf.lastStat = time.Now().Add(-bulkStatInterval)
+	_, ok = buf.statIfDue()
+	assert.False(t, ok)
+	assert.Equal(t, BulkStats{Dropped: 2}, buf.stats())
+}
+
+func TestBulkTableName(t *testing.T) {
+	assert.Equal(t, "user", bulkTableName("insert into user(id, name) values"))
+	assert.Equal(t, "db.user", bulkTableName("INSERT IGNORE INTO db.user (id, name) VALUES"))
+	assert.Equal(t, "user", bulkTableName("insert into user values"))
+}
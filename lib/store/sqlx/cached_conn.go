@@ -1,6 +1,7 @@
 package sqlx
 
 import (
+	"context"
 	"database/sql"
 	"github.com/z-sdk/goa/lib/store/cache"
 	"github.com/z-sdk/goa/lib/store/redis"
@@ -22,8 +23,10 @@ type (
 		cache cache.Cache
 	}
 
-	ExecFn  func(conn Conn) (sql.Result, error)     // 常规的写库函数
-	QueryFn func(conn Conn, dest interface{}) error // 常规的读库函数
+	ExecFn     func(conn Conn) (sql.Result, error)                          // 常规的写库函数
+	ExecCtxFn  func(ctx context.Context, conn Conn) (sql.Result, error)     // 带上下文的写库函数
+	QueryFn    func(conn Conn, dest interface{}) error                      // 常规的读库函数
+	QueryCtxFn func(ctx context.Context, conn Conn, dest interface{}) error // 带上下文的读库函数
 
 	GetKeyOfPKFn   func(pk interface{}) string                                   // 取主键的缓存键
 	IndexQueryFn   func(conn Conn, dest interface{}) (pk interface{}, err error) // 按索引查行结果
@@ -70,11 +73,23 @@ func (cc CachedConn) Exec(exec ExecFn, keys ...string) (sql.Result, error) {
 	return result, nil
 }
 
+// ExecCtx 带上下文执行增、删、改，并清空 keys 对应的缓存
+func (cc CachedConn) ExecCtx(ctx context.Context, exec ExecCtxFn, keys ...string) (sql.Result, error) {
+	return cc.Exec(func(conn Conn) (sql.Result, error) {
+		return exec(ctx, conn)
+	}, keys...)
+}
+
 // ExecNoCache 无缓存执行增、删、改
 func (cc CachedConn) ExecNoCache(query string, args ...interface{}) (sql.Result, error) {
 	return cc.conn.Exec(query, args)
 }
 
+// ExecNoCacheCtx 带上下文无缓存执行增、删、改
+func (cc CachedConn) ExecNoCacheCtx(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return cc.conn.ExecCtx(ctx, query, args...)
+}
+
 // Query 先按 key 从缓存拿，拿不到则查库、写缓存并返回新值
 func (cc CachedConn) Query(dest interface{}, key string, query QueryFn) error {
 	return cc.cache.Take(dest, key, func(dbValue interface{}) error {
@@ -82,15 +97,39 @@ func (cc CachedConn) Query(dest interface{}, key string, query QueryFn) error {
 	})
 }
 
+// QueryCtx 带上下文的缓存查询，ctx 仅作用于查库阶段
+func (cc CachedConn) QueryCtx(ctx context.Context, dest interface{}, key string, query QueryCtxFn) error {
+	return cc.cache.Take(dest, key, func(dbValue interface{}) error {
+		return query(ctx, cc.conn, dbValue)
+	})
+}
+
 // QueryNoCache 无缓存查询，直接读库
 func (cc CachedConn) QueryNoCache(dest interface{}, query string, args ...interface{}) error {
 	return cc.conn.Query(dest, query, args...)
 }
 
+// QueryNoCacheCtx 带上下文无缓存查询，直接读库
+func (cc CachedConn) QueryNoCacheCtx(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return cc.conn.QueryCtx(ctx, dest, query, args...)
+}
+
 func (cc CachedConn) Transact(fn func(Session) error) error {
 	return cc.conn.Transact(fn)
 }
 
+func (cc CachedConn) TransactCtx(ctx context.Context, fn func(Session) error) error {
+	return cc.conn.TransactCtx(ctx, fn)
+}
+
+func (cc CachedConn) TransactWithOptions(opts TxOptions, fn func(Session) error) error {
+	return cc.conn.TransactWithOptions(opts, fn)
+}
+
+func (cc CachedConn) TransactWithOptionsCtx(ctx context.Context, opts TxOptions, fn func(Session) error) error {
+	return cc.conn.TransactWithOptionsCtx(ctx, opts, fn)
+}
+
 func (cc CachedConn) QueryIndex(dest interface{}, indexKey string, getKeyOfPK GetKeyOfPKFn,
 	indexQuery IndexQueryFn, primaryQuery PrimaryQueryFn) error {
 	var id interface{}
@@ -118,8 +157,8 @@ func (cc CachedConn) QueryIndex(dest interface{}, indexKey string, getKeyOfPK Ge
 	}
 
 	// 通过索引建能直接查到主键，则直接做主键查询
-	return cc.cache.Take(dest, getKeyOfPK(id), func(interface{}) error {
-		return primaryQuery(cc.conn, dest, id)
+	return cc.cache.Take(dest, getKeyOfPK(id), func(dbValue interface{}) error {
+		return primaryQuery(cc.conn, dbValue, id)
 	})
 }
 
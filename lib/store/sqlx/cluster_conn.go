@@ -0,0 +1,256 @@
+package sqlx
+
+import (
+	"context"
+	"database/sql"
+	"github.com/z-sdk/goa/lib/breaker"
+	"github.com/z-sdk/goa/lib/errorx"
+	"io"
+	"sync/atomic"
+)
+
+type (
+	// clusterConn 读写分离的数据库连接：写和事务走主库，读按权重轮询到健康的从库
+	clusterConn struct {
+		primary     Conn
+		replicas    []Conn
+		weights     []int
+		totalWeight int
+		readPrimary bool
+		counter     uint64
+		connOpts    []Option
+	}
+
+	// ClusterOption 是读写分离连接的可选增强函数
+	ClusterOption func(c *clusterConn)
+
+	// 强制读主库的上下文标记
+	primaryCtxKey struct{}
+)
+
+// NewMySQLCluster 创建一主多从的 MySQL 读写分离连接，每个从库各自拥有断路器
+func NewMySQLCluster(primary string, replicas []string, opts ...ClusterOption) Conn {
+	c := new(clusterConn)
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	c.primary = NewMySQL(primary, c.connOpts...)
+	for _, replica := range replicas {
+		c.replicas = append(c.replicas, NewMySQL(replica, c.connOpts...))
+	}
+	c.setupWeights()
+
+	return c
+}
+
+// setupWeights 校验从库权重，未配置或配置有误的权重，视为各从库等权
+func (c *clusterConn) setupWeights() {
+	if len(c.weights) != len(c.replicas) {
+		c.weights = make([]int, len(c.replicas))
+		for i := range c.weights {
+			c.weights[i] = 1
+		}
+	}
+
+	c.totalWeight = 0
+	for _, weight := range c.weights {
+		if weight > 0 {
+			c.totalWeight += weight
+		}
+	}
+}
+
+// WithReplicaWeights 按从库顺序设置读请求的分配权重，权重为0的从库只在其他从库不可用时使用
+func WithReplicaWeights(weights ...int) ClusterOption {
+	return func(c *clusterConn) {
+		c.weights = weights
+	}
+}
+
+// WithPrimaryRead 所有读请求都走主库
+func WithPrimaryRead() ClusterOption {
+	return func(c *clusterConn) {
+		c.readPrimary = true
+	}
+}
+
+// WithConnOptions 设置主库和从库共用的连接选项
+func WithConnOptions(opts ...Option) ClusterOption {
+	return func(c *clusterConn) {
+		c.connOpts = append(c.connOpts, opts...)
+	}
+}
+
+// ForcePrimary 返回强制读主库的上下文，用于写后立即读（read-your-writes）
+func ForcePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryCtxKey{}, true)
+}
+
+func isPrimaryForced(ctx context.Context) bool {
+	forced, ok := ctx.Value(primaryCtxKey{}).(bool)
+	return ok && forced
+}
+
+func (c *clusterConn) Query(dest interface{}, query string, args ...interface{}) error {
+	return c.QueryCtx(context.Background(), dest, query, args...)
+}
+
+// QueryCtx 从健康的从库读，从库的断路器均已打开时退回主库
+func (c *clusterConn) QueryCtx(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return c.read(ctx, func(conn Conn) error {
+		return conn.QueryCtx(ctx, dest, query, args...)
+	})
+}
+
+func (c *clusterConn) QueryStream(dest interface{}, fn StreamFn, query string, args ...interface{}) error {
+	return c.QueryStreamCtx(context.Background(), dest, fn, query, args...)
+}
+
+// QueryStreamCtx 从健康的从库流式读，从库的断路器均已打开时退回主库
+func (c *clusterConn) QueryStreamCtx(ctx context.Context, dest interface{}, fn StreamFn, query string,
+	args ...interface{}) error {
+	return c.read(ctx, func(conn Conn) error {
+		return conn.QueryStreamCtx(ctx, dest, fn, query, args...)
+	})
+}
+
+func (c *clusterConn) QueryNamed(dest interface{}, query string, arg interface{}) error {
+	return c.QueryNamedCtx(context.Background(), dest, query, arg)
+}
+
+func (c *clusterConn) QueryNamedCtx(ctx context.Context, dest interface{}, query string, arg interface{}) error {
+	query, args, err := bindNamed(query, arg)
+	if err != nil {
+		return err
+	}
+
+	return c.QueryCtx(ctx, dest, query, args...)
+}
+
+func (c *clusterConn) QueryPage(dest interface{}, query string, page, size int, args ...interface{}) (int64, error) {
+	return c.QueryPageCtx(context.Background(), dest, query, page, size, args...)
+}
+
+// QueryPageCtx 从健康的从库按页码查询，总条数和当页数据读自同一个库
+func (c *clusterConn) QueryPageCtx(ctx context.Context, dest interface{}, query string, page, size int,
+	args ...interface{}) (total int64, err error) {
+	err = c.read(ctx, func(conn Conn) error {
+		total, err = conn.QueryPageCtx(ctx, dest, query, page, size, args...)
+		return err
+	})
+	return
+}
+
+func (c *clusterConn) QueryAfter(dest interface{}, query, cursorColumn, cursor string, size int,
+	args ...interface{}) (string, error) {
+	return c.QueryAfterCtx(context.Background(), dest, query, cursorColumn, cursor, size, args...)
+}
+
+// QueryAfterCtx 从健康的从库按游标查询
+func (c *clusterConn) QueryAfterCtx(ctx context.Context, dest interface{}, query, cursorColumn, cursor string,
+	size int, args ...interface{}) (next string, err error) {
+	err = c.read(ctx, func(conn Conn) error {
+		next, err = conn.QueryAfterCtx(ctx, dest, query, cursorColumn, cursor, size, args...)
+		return err
+	})
+	return
+}
+
+func (c *clusterConn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.primary.Exec(query, args...)
+}
+
+func (c *clusterConn) ExecCtx(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return c.primary.ExecCtx(ctx, query, args...)
+}
+
+// PoolStats 返回主库连接池的统计快照
+func (c *clusterConn) PoolStats() sql.DBStats {
+	return c.primary.PoolStats()
+}
+
+func (c *clusterConn) ExecNamed(query string, arg interface{}) (sql.Result, error) {
+	return c.primary.ExecNamed(query, arg)
+}
+
+func (c *clusterConn) ExecNamedCtx(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
+	return c.primary.ExecNamedCtx(ctx, query, arg)
+}
+
+func (c *clusterConn) Transact(fn TransactFn) error {
+	return c.primary.Transact(fn)
+}
+
+func (c *clusterConn) TransactCtx(ctx context.Context, fn TransactFn) error {
+	return c.primary.TransactCtx(ctx, fn)
+}
+
+func (c *clusterConn) TransactWithOptions(opts TxOptions, fn TransactFn) error {
+	return c.primary.TransactWithOptions(opts, fn)
+}
+
+func (c *clusterConn) TransactWithOptionsCtx(ctx context.Context, opts TxOptions, fn TransactFn) error {
+	return c.primary.TransactWithOptionsCtx(ctx, opts, fn)
+}
+
+// Prepare 预处理语句可能用于写，统一在主库上预处理
+func (c *clusterConn) Prepare(query string) (StmtSession, error) {
+	return c.primary.Prepare(query)
+}
+
+// read 按权重轮询选择从库执行读请求，被断路器拒绝时依次尝试下一个从库，均不可用时退回主库
+func (c *clusterConn) read(ctx context.Context, query func(conn Conn) error) error {
+	if c.readPrimary || len(c.replicas) == 0 || isPrimaryForced(ctx) {
+		return query(c.primary)
+	}
+
+	start := c.nextReplica()
+	for i := 0; i < len(c.replicas); i++ {
+		replica := c.replicas[(start+i)%len(c.replicas)]
+		if err := query(replica); err != breaker.ErrServiceUnavaliable {
+			return err
+		}
+	}
+
+	return query(c.primary)
+}
+
+// Close 关闭主库和各从库的连接池
+func (c *clusterConn) Close() error {
+	var es errorx.Errors
+	for _, conn := range append([]Conn{c.primary}, c.replicas...) {
+		if closer, ok := conn.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				es.Add(err)
+			}
+		}
+	}
+
+	return es.Error()
+}
+
+func (c *clusterConn) getDialect() dialect {
+	return dialectOf(c.primary)
+}
+
+// nextReplica 按权重轮询选出本次读请求优先使用的从库序号
+func (c *clusterConn) nextReplica() int {
+	count := atomic.AddUint64(&c.counter, 1) - 1
+	if c.totalWeight <= 0 {
+		return int(count % uint64(len(c.replicas)))
+	}
+
+	slot := int(count % uint64(c.totalWeight))
+	for i, weight := range c.weights {
+		if weight <= 0 {
+			continue
+		}
+		if slot < weight {
+			return i
+		}
+		slot -= weight
+	}
+
+	return 0
+}
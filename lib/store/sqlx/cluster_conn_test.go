@@ -0,0 +1,64 @@
+package sqlx
+
+import (
+	"context"
+	"database/sql/driver"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newMockedCluster(name string, weights ...int) (*clusterConn, []*mockedBackend) {
+	dsns := []string{name + "_primary", name + "_replica0", name + "_replica1"}
+	backends := make([]*mockedBackend, len(dsns))
+	conns := make([]Conn, len(dsns))
+	for i, dsn := range dsns {
+		backends[i] = newMockedBackend(dsn + "?parseTime=true&loc=Local")
+		backends[i].setRows([]string{"id"}, []driver.Value{int64(i)})
+		conns[i] = NewConn(mockedDriverName, dsn)
+	}
+
+	c := &clusterConn{
+		primary:  conns[0],
+		replicas: conns[1:],
+		weights:  weights,
+	}
+	c.setupWeights()
+	return c, backends
+}
+
+func TestClusterConn_ReadWriteSplit(t *testing.T) {
+	c, backends := newMockedCluster("cluster_split")
+
+	// 读请求轮询从库
+	for i := 0; i < 4; i++ {
+		var id int64
+		assert.Nil(t, c.Query(&id, "select id from user"))
+		assert.Equal(t, int64(i%2+1), id)
+	}
+
+	// 写请求只走主库
+	_, err := c.Exec("delete from user")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"delete from user"}, backends[0].statements())
+	assert.Len(t, backends[1].statements(), 2)
+	assert.Len(t, backends[2].statements(), 2)
+}
+
+func TestClusterConn_ForcePrimary(t *testing.T) {
+	c, backends := newMockedCluster("cluster_force")
+
+	var id int64
+	assert.Nil(t, c.QueryCtx(ForcePrimary(context.Background()), &id, "select id from user"))
+	assert.Equal(t, int64(0), id)
+	assert.Len(t, backends[0].statements(), 1)
+}
+
+func TestClusterConn_Weights(t *testing.T) {
+	c, _ := newMockedCluster("cluster_weights", 0, 3)
+
+	for i := 0; i < 6; i++ {
+		var id int64
+		assert.Nil(t, c.Query(&id, "select id from user"))
+		assert.Equal(t, int64(2), id)
+	}
+}
@@ -0,0 +1,31 @@
+package sqlx
+
+import "time"
+
+const (
+	defaultMaxOpenConns = 64          // 默认允许最大的打开连接数
+	defaultMaxIdleConns = 64          // 默认允许的最大空闲连接数
+	defaultMaxLifetime  = time.Minute // 默认允许的连接最大存活时间
+)
+
+// PoolConf 数据库连接池配置，同一DSN共享一个连接池，配置以首次建池时为准
+type PoolConf struct {
+	MaxOpenConns int           `json:",default=64"` // 允许最大的打开连接数
+	MaxIdleConns int           `json:",default=64"` // 允许的最大空闲连接数
+	MaxLifetime  time.Duration `json:",default=1m"` // 允许的连接最大存活时间
+	MaxIdleTime  time.Duration `json:",optional"`   // 允许的连接最大空闲时间，0 表示不限
+}
+
+// withDefaults 未设置的配置项使用默认值
+func (pc PoolConf) withDefaults() PoolConf {
+	if pc.MaxOpenConns <= 0 {
+		pc.MaxOpenConns = defaultMaxOpenConns
+	}
+	if pc.MaxIdleConns <= 0 {
+		pc.MaxIdleConns = defaultMaxIdleConns
+	}
+	if pc.MaxLifetime <= 0 {
+		pc.MaxLifetime = defaultMaxLifetime
+	}
+	return pc
+}
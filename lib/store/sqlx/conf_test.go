@@ -0,0 +1,27 @@
+package sqlx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestPoolConf_WithDefaults(t *testing.T) {
+	pool := PoolConf{MaxOpenConns: 8}.withDefaults()
+	assert.Equal(t, 8, pool.MaxOpenConns)
+	assert.Equal(t, defaultMaxIdleConns, pool.MaxIdleConns)
+	assert.Equal(t, defaultMaxLifetime, pool.MaxLifetime)
+	assert.Equal(t, time.Duration(0), pool.MaxIdleTime)
+}
+
+func TestConn_PoolStats(t *testing.T) {
+	const dsn = "pool_stats"
+	newMockedBackend(dsn + "?parseTime=true&loc=Local")
+	c := NewConn(mockedDriverName, dsn, WithPoolConf(PoolConf{MaxOpenConns: 3}))
+
+	_, err := c.Exec("delete from user")
+	assert.Nil(t, err)
+	stats := c.PoolStats()
+	assert.Equal(t, 3, stats.MaxOpenConnections)
+	assert.Equal(t, 1, stats.OpenConnections)
+}
@@ -1,9 +1,9 @@
 package sqlx
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"fmt"
 	"github.com/z-sdk/goa/lib/breaker"
 	"time"
 )
@@ -12,8 +12,8 @@ const (
 	// 结构体字段中，数据库字段的标记名称
 	tagName = "db"
 
-	// 数据库慢日志阈值，用于记录慢查询和慢执行
-	slowThreshold = 500 * time.Millisecond
+	// 默认的数据库慢日志阈值，用于记录慢查询和慢执行
+	defaultSlowThreshold = 500 * time.Millisecond
 )
 
 var (
@@ -24,25 +24,60 @@ var (
 )
 
 type (
-	// Session 提供外部查询和执行的会话接口
+	// Session 提供外部查询、执行和事务的会话接口
+	// 在事务会话上调用 Transact 会以保存点开启嵌套事务，因此接收 Session 的方法既可独立运行，也可加入调用方的事务
 	Session interface {
 		Query(dest interface{}, query string, args ...interface{}) error
+		QueryCtx(ctx context.Context, dest interface{}, query string, args ...interface{}) error
 		Exec(query string, args ...interface{}) (sql.Result, error)
+		ExecCtx(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+		Transact(fn TransactFn) error
+		TransactCtx(ctx context.Context, fn TransactFn) error
+
+		// 命名参数版本，query 中以 :name 引用 arg 的字段，arg 为结构体或 map[string]interface{}
+		QueryNamed(dest interface{}, query string, arg interface{}) error
+		QueryNamedCtx(ctx context.Context, dest interface{}, query string, arg interface{}) error
+		ExecNamed(query string, arg interface{}) (sql.Result, error)
+		ExecNamedCtx(ctx context.Context, query string, arg interface{}) (sql.Result, error)
 	}
 
-	// 提供内部查询和执行的会话接口
+	// 提供内部查询和执行的会话接口，*sql.DB 和 *sql.Tx 均已实现
 	session interface {
-		Query(query string, args ...interface{}) (*sql.Rows, error)
-		Exec(query string, args ...interface{}) (sql.Result, error)
+		QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+		ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
 	}
 
+	// StmtSession 提供预处理语句的查询和执行接口，用完需调用 Close
+	StmtSession interface {
+		Close() error
+		Query(dest interface{}, args ...interface{}) error
+		QueryCtx(ctx context.Context, dest interface{}, args ...interface{}) error
+		Exec(args ...interface{}) (sql.Result, error)
+		ExecCtx(ctx context.Context, args ...interface{}) (sql.Result, error)
+	}
+
+	// StreamFn 流式查询的逐行回调，row 是每行新分配的目标类型指针，返回错误则停止读取
+	StreamFn func(row interface{}) error
+
 	// TransactFn 事务内部执行函数，传入事务会话
 	TransactFn func(tx Session) error
 
 	// Conn 提供外部数据库会话和事务的接口
 	Conn interface {
 		Session
-		Transact(fn TransactFn) error
+		TransactWithOptions(opts TxOptions, fn TransactFn) error
+		TransactWithOptionsCtx(ctx context.Context, opts TxOptions, fn TransactFn) error
+		QueryStream(dest interface{}, fn StreamFn, query string, args ...interface{}) error
+		QueryStreamCtx(ctx context.Context, dest interface{}, fn StreamFn, query string, args ...interface{}) error
+		// QueryPage 按页码查询，page 从 1 开始，结果写入 dest 并返回总条数，query 为不含 limit 的查询语句
+		QueryPage(dest interface{}, query string, page, size int, args ...interface{}) (int64, error)
+		QueryPageCtx(ctx context.Context, dest interface{}, query string, page, size int, args ...interface{}) (int64, error)
+		// QueryAfter 按游标查询 cursor 之后的 size 行，结果写入 dest，返回下一页的游标，没有更多数据时返回空串
+		QueryAfter(dest interface{}, query, cursorColumn, cursor string, size int, args ...interface{}) (string, error)
+		QueryAfterCtx(ctx context.Context, dest interface{}, query, cursorColumn, cursor string, size int,
+			args ...interface{}) (string, error)
+		Prepare(query string) (StmtSession, error)
+		PoolStats() sql.DBStats
 	}
 
 	// conn 内部使用的数据库连接，封装查询、执行、事务及断路器支持
@@ -52,6 +87,13 @@ type (
 		beginTx        beginTxFn       // 可开始事务
 		brk            breaker.Breaker // 断路器，用于后端故障拒绝服务
 		accept         func(reqError error) bool
+		retryable      func(reqError error) bool // 判断事务出错后是否可以重跑
+		stmtCacheSize  int                       // 预处理语句缓存容量，0 表示不缓存
+		pool           PoolConf                  // 连接池配置
+		slowThreshold  time.Duration             // 慢日志阈值
+		logAllStmts    bool                      // 是否记录全部语句，默认仅记录慢语句
+		dialect        dialect                   // 数据库方言
+		interceptors   []Interceptor             // 语句拦截器
 	}
 
 	// Option 是一个可选的数据库增强函数
@@ -60,67 +102,268 @@ type (
 
 // NewConn 新建指定数据库驱动和DSN的连接
 func NewConn(driverName, dataSourceName string, opts ...Option) Conn {
-	prefectDSN(&dataSourceName)
-
 	c := &conn{
 		driverName:     driverName,
 		dataSourceName: dataSourceName,
 		beginTx:        beginTx,
 		brk:            breaker.NewBreaker(),
+		slowThreshold:  defaultSlowThreshold,
+		dialect:        mysqlDialect{},
 	}
 	for _, opt := range opts {
 		opt(c)
 	}
+	c.dataSourceName = c.dialect.perfectDSN(c.dataSourceName)
+
 	return c
 }
 
+// WithPoolConf 设置连接池配置，未设置的配置项使用默认值
+func WithPoolConf(pool PoolConf) Option {
+	return func(c *conn) {
+		c.pool = pool
+	}
+}
+
+// WithSlowThreshold 设置慢日志阈值，执行时间超过该阈值的语句记入慢日志
+func WithSlowThreshold(threshold time.Duration) Option {
+	return func(c *conn) {
+		c.slowThreshold = threshold
+	}
+}
+
+// WithStmtLog 记录全部语句的日志，用于调试
+func WithStmtLog() Option {
+	return func(c *conn) {
+		c.logAllStmts = true
+	}
+}
+
+// WithStmtCache 开启容量为 size 的预处理语句 LRU 缓存，同一DSN下按 SQL 复用预处理语句
+func WithStmtCache(size int) Option {
+	return func(c *conn) {
+		c.stmtCacheSize = size
+	}
+}
+
 // 如果 dest 字段不写tag的话，系统按顺序配对，此时需要与sql中的查询字段顺序一致
 // 如果 dest 字段写了tag的话，系统按名称配对，此时可以和sql中的查询字段顺序不同
 func (c *conn) Query(dest interface{}, query string, args ...interface{}) error {
+	return c.QueryCtx(context.Background(), dest, query, args...)
+}
+
+// QueryCtx 带上下文查询，ctx 的截止时间和取消信号会传递给数据库驱动
+func (c *conn) QueryCtx(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
 	var scanError error
 	return c.brk.DoWithAcceptable(func() error {
 		// 获取数据库连接
-		db, err := getConn(c.driverName, c.dataSourceName)
+		db, err := getConn(c.driverName, c.dataSourceName, c.pool)
 		if err != nil {
 			logConnError(c.dataSourceName, err)
 			return err
 		}
 
 		// 做数据库查询
-		return doQuery(db, func(rows *sql.Rows) error {
-			scanError = scan(rows, dest)
-			return scanError
+		return doQuery(ctx, c, db, MethodQuery, func(rows *sql.Rows) (int64, error) {
+			n, err := scan(rows, dest)
+			scanError = err
+			return n, err
 		}, query, args...)
 	}, func(reqError error) bool {
-		return reqError == scanError || c.acceptable(reqError)
+		return reqError == scanError || c.acceptableCtx(ctx, reqError)
 	})
 }
 
-func (c *conn) Exec(query string, args ...interface{}) (result sql.Result, err error) {
-	err = c.brk.DoWithAcceptable(func() error {
-		fmt.Println("获取连接并做数据库执行")
-		fmt.Println()
+// QueryStream 流式查询，适用于导出等大结果集场景。
+// dest 为单行目标类型的指针（如 &User{}），仅用于确定类型，每行扫描进新分配的值并交给 fn 处理
+func (c *conn) QueryStream(dest interface{}, fn StreamFn, query string, args ...interface{}) error {
+	return c.QueryStreamCtx(context.Background(), dest, fn, query, args...)
+}
+
+// QueryStreamCtx 带上下文的流式查询
+func (c *conn) QueryStreamCtx(ctx context.Context, dest interface{}, fn StreamFn, query string,
+	args ...interface{}) error {
+	var scanError error
+	return c.brk.DoWithAcceptable(func() error {
+		// 获取数据库连接
+		db, err := getConn(c.driverName, c.dataSourceName, c.pool)
+		if err != nil {
+			logConnError(c.dataSourceName, err)
+			return err
+		}
+
+		// 做数据库流式查询
+		return doQuery(ctx, c, db, MethodQuery, func(rows *sql.Rows) (int64, error) {
+			n, err := scanStream(rows, dest, fn)
+			scanError = err
+			return n, err
+		}, query, args...)
+	}, func(reqError error) bool {
+		return reqError == scanError || c.acceptableCtx(ctx, reqError)
+	})
+}
+
+func (c *conn) QueryNamed(dest interface{}, query string, arg interface{}) error {
+	return c.QueryNamedCtx(context.Background(), dest, query, arg)
+}
+
+func (c *conn) QueryNamedCtx(ctx context.Context, dest interface{}, query string, arg interface{}) error {
+	query, args, err := bindNamed(query, arg)
+	if err != nil {
+		return err
+	}
+
+	return c.QueryCtx(ctx, dest, query, args...)
+}
+
+func (c *conn) QueryPage(dest interface{}, query string, page, size int, args ...interface{}) (int64, error) {
+	return c.QueryPageCtx(context.Background(), dest, query, page, size, args...)
+}
+
+// QueryPageCtx 带上下文按页码查询，总条数由 select count(*) from (query) 查得
+func (c *conn) QueryPageCtx(ctx context.Context, dest interface{}, query string, page, size int,
+	args ...interface{}) (int64, error) {
+	return queryPage(ctx, c, dest, query, page, size, args...)
+}
+
+func (c *conn) QueryAfter(dest interface{}, query, cursorColumn, cursor string, size int,
+	args ...interface{}) (string, error) {
+	return c.QueryAfterCtx(context.Background(), dest, query, cursorColumn, cursor, size, args...)
+}
 
+// QueryAfterCtx 带上下文按游标查询，以 cursorColumn 的值定位，避免深分页时扫描 offset 行，
+// cursorColumn 须唯一且在 query 的查询列中，可带 desc 后缀表示倒序，首页 cursor 传空串
+func (c *conn) QueryAfterCtx(ctx context.Context, dest interface{}, query, cursorColumn, cursor string, size int,
+	args ...interface{}) (string, error) {
+	return queryAfter(ctx, c, dest, query, cursorColumn, cursor, size, args...)
+}
+
+func (c *conn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.ExecCtx(context.Background(), query, args...)
+}
+
+// ExecCtx 带上下文执行，ctx 的截止时间和取消信号会传递给数据库驱动
+func (c *conn) ExecCtx(ctx context.Context, query string, args ...interface{}) (result sql.Result, err error) {
+	err = c.brk.DoWithAcceptable(func() error {
 		// 获取数据库连接
-		db, err := getConn(c.driverName, c.dataSourceName)
+		db, err := getConn(c.driverName, c.dataSourceName, c.pool)
 		if err != nil {
 			logConnError(c.dataSourceName, err)
 			return err
 		}
 
 		// 做数据库执行
-		result, err = doExec(db, query, args...)
+		result, err = doExec(ctx, c, db, methodOf(ctx, MethodExec), query, args...)
 		return err
-	}, c.acceptable)
+	}, func(reqError error) bool {
+		return c.acceptableCtx(ctx, reqError)
+	})
 	return
 }
 
+func (c *conn) ExecNamed(query string, arg interface{}) (sql.Result, error) {
+	return c.ExecNamedCtx(context.Background(), query, arg)
+}
+
+func (c *conn) ExecNamedCtx(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
+	query, args, err := bindNamed(query, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.ExecCtx(ctx, query, args...)
+}
+
 func (c *conn) Transact(fn TransactFn) error {
-	return c.brk.DoWithAcceptable(func() error {
-		fmt.Println("获取连接并做数据库事务")
-		fmt.Println()
-		return doTx(c, c.beginTx, fn)
+	return c.TransactCtx(context.Background(), fn)
+}
+
+// TransactCtx 带上下文的事务，ctx 取消时事务会被驱动回滚
+func (c *conn) TransactCtx(ctx context.Context, fn TransactFn) error {
+	return c.TransactWithOptionsCtx(ctx, TxOptions{}, fn)
+}
+
+// TransactWithOptions 按指定的隔离级别、只读和重试策略执行事务
+func (c *conn) TransactWithOptions(opts TxOptions, fn TransactFn) error {
+	return c.TransactWithOptionsCtx(context.Background(), opts, fn)
+}
+
+// TransactWithOptionsCtx 按指定的隔离级别、只读和重试策略执行带上下文的事务，每次重跑都经过断路器
+func (c *conn) TransactWithOptionsCtx(ctx context.Context, opts TxOptions, fn TransactFn) error {
+	return doTxWithRetry(ctx, opts.Retry, c.retryable, func() error {
+		return c.brk.DoWithAcceptable(func() error {
+			return doTx(ctx, c, c.beginTx, &opts.TxOptions, fn)
+		}, func(reqError error) bool {
+			return c.acceptableCtx(ctx, reqError)
+		})
+	})
+}
+
+// Prepare 预处理 query 语句，返回可复用的语句会话
+func (c *conn) Prepare(query string) (stmt StmtSession, err error) {
+	err = c.brk.DoWithAcceptable(func() error {
+		st, release, err := c.prepare(context.Background(), query)
+		if err != nil {
+			return err
+		}
+		// 缓存的语句在每次执行时重新获取，此处仅验证语句
+		release()
+
+		stmt = statement{
+			conn:  c,
+			query: query,
+			stmt:  st,
+		}
+		return nil
 	}, c.acceptable)
+	return
+}
+
+// prepare 预处理 query 语句，用完后须调用返回的 release，未启用语句缓存时 release 不做任何事
+func (c *conn) prepare(ctx context.Context, query string) (stmt *sql.Stmt, release func(), err error) {
+	// 获取数据库连接
+	db, err := getPingedConn(c.driverName, c.dataSourceName, c.pool)
+	if err != nil {
+		logConnError(c.dataSourceName, err)
+		return nil, nil, err
+	}
+
+	if c.stmtCacheSize > 0 {
+		return db.stmtCache(c.stmtCacheSize).prepare(ctx, db.DB, c.dialect.rebind(query))
+	}
+
+	stmt, err = db.PrepareContext(ctx, c.dialect.rebind(query))
+	return stmt, func() {}, err
+}
+
+// PoolStats 返回连接池的统计快照，用于观察连接池是否饱和
+func (c *conn) PoolStats() sql.DBStats {
+	db, err := getCachedConn(c.driverName, c.dataSourceName, c.pool)
+	if err != nil {
+		logConnError(c.dataSourceName, err)
+		return sql.DBStats{}
+	}
+
+	return db.Stats()
+}
+
+// Close 关闭该数据源的连接池并从缓存中移除，同一数据源的其他连接共用该连接池，再次使用时会新建。
+// 生命周期短于程序的连接（如测试）用完后可经 io.Closer 关闭
+func (c *conn) Close() error {
+	return connManager.Remove(c.dataSourceName)
+}
+
+func (c *conn) getDialect() dialect {
+	return c.dialect
+}
+
+// rebind 有参数时按方言改写占位符
+func (c *conn) rebind(query string, args []interface{}) string {
+	if len(args) == 0 {
+		return query
+	}
+
+	return c.dialect.rebind(query)
 }
 
 func (c *conn) acceptable(reqError error) bool {
@@ -133,3 +376,10 @@ func (c *conn) acceptable(reqError error) bool {
 		return ok || c.accept(reqError)
 	}
 }
+
+// acceptableCtx 调用方主动取消的请求不是后端故障，不计入断路器的失败次数
+func (c *conn) acceptableCtx(ctx context.Context, reqError error) bool {
+	return reqError == context.Canceled ||
+		ctx.Err() == context.Canceled ||
+		c.acceptable(reqError)
+}
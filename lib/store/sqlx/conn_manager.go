@@ -5,13 +5,6 @@ import (
 	"github.com/z-sdk/goa/lib/syncx"
 	"io"
 	"sync"
-	"time"
-)
-
-const (
-	maxOpenConns = 64          // 允许最大的打开连接数
-	maxIdleConns = 64          // 允许的最大空闲连接数
-	maxLifetime  = time.Minute // 允许的最大连接空闲时间
 )
 
 var connManager = syncx.NewResourceManager()
@@ -19,12 +12,24 @@ var connManager = syncx.NewResourceManager()
 // 缓存的数据库连接结构
 type cachedConn struct {
 	*sql.DB
-	once sync.Once
+	once     sync.Once
+	stmtOnce sync.Once
+	stmts    *stmtCache
 }
 
 // getConn 从缓存池中获取可复用的数据库
-func getConn(driverName, dataSourceName string) (*sql.DB, error) {
-	conn, err := getCachedConn(driverName, dataSourceName)
+func getConn(driverName, dataSourceName string, pool PoolConf) (*sql.DB, error) {
+	conn, err := getPingedConn(driverName, dataSourceName, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	return conn.DB, nil
+}
+
+// getPingedConn 从缓存池中获取已连通的缓存连接
+func getPingedConn(driverName, dataSourceName string, pool PoolConf) (*cachedConn, error) {
+	conn, err := getCachedConn(driverName, dataSourceName, pool)
 	if err != nil {
 		return nil, err
 	}
@@ -37,15 +42,15 @@ func getConn(driverName, dataSourceName string) (*sql.DB, error) {
 		return nil, err
 	}
 
-	return conn.DB, nil
+	return conn, nil
 }
 
 // getCachedConn 从缓存池中获取连接
-func getCachedConn(driverName, dataSourceName string) (*cachedConn, error) {
+func getCachedConn(driverName, dataSourceName string, pool PoolConf) (*cachedConn, error) {
 	// 一个DSN，对应一个缓存连接
 	cc, err := connManager.Get(dataSourceName, func() (io.Closer, error) {
 		// 无缓存连接，则新建并通过该函数回调并加入缓存
-		conn, err := newConn(driverName, dataSourceName)
+		conn, err := newConn(driverName, dataSourceName, pool)
 		if err != nil {
 			return nil, err
 		}
@@ -58,15 +63,25 @@ func getCachedConn(driverName, dataSourceName string) (*cachedConn, error) {
 }
 
 // newConn 新建数据库连接
-func newConn(driverName, dataSourceName string) (*sql.DB, error) {
+func newConn(driverName, dataSourceName string, pool PoolConf) (*sql.DB, error) {
 	db, err := sql.Open(driverName, dataSourceName)
 	if err != nil {
 		return nil, err
 	}
 
-	db.SetMaxOpenConns(maxOpenConns)
-	db.SetMaxIdleConns(maxIdleConns)
-	db.SetConnMaxLifetime(maxLifetime)
+	pool = pool.withDefaults()
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxLifetime(pool.MaxLifetime)
+	db.SetConnMaxIdleTime(pool.MaxIdleTime)
 
 	return db, nil
 }
+
+// stmtCache 获取该连接的预处理语句缓存，同一DSN共享一个缓存，容量以首次创建时为准
+func (cc *cachedConn) stmtCache(size int) *stmtCache {
+	cc.stmtOnce.Do(func() {
+		cc.stmts = newStmtCache(size)
+	})
+	return cc.stmts
+}
@@ -1,7 +1,12 @@
 package sqlx
 
 import (
+	"context"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/z-sdk/goa/lib/breaker"
 	"strconv"
 	"testing"
 	"time"
@@ -134,3 +139,54 @@ func TestConnBreaker(t *testing.T) {
 		_ = db.Query(&book, "select book from bookx limit ?", i)
 	}
 }
+
+func TestBreakerOnCanceledContext(t *testing.T) {
+	c := &conn{
+		brk: breaker.NewBreaker(),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// 调用方主动取消的请求，无论多少次都不应打开断路器
+	for i := 0; i < 1000; i++ {
+		assert.Equal(t, context.Canceled, c.brk.DoWithAcceptable(func() error {
+			return context.Canceled
+		}, func(reqError error) bool {
+			return c.acceptableCtx(ctx, reqError)
+		}))
+	}
+}
+
+func TestConn_QueryStream(t *testing.T) {
+	const dsn = "query_stream"
+	backend := newMockedBackend(dsn + "?parseTime=true&loc=Local")
+	backend.setRows([]string{"name", "id"},
+		[]driver.Value{"张三", int64(1)},
+		[]driver.Value{"李四", int64(2)},
+		[]driver.Value{"王五", int64(3)})
+	c := NewConn(mockedDriverName, dsn)
+
+	type user struct {
+		Id   int64  `db:"id"`
+		Name string `db:"name"`
+	}
+	var users []*user
+	assert.Nil(t, c.QueryStream(&user{}, func(row interface{}) error {
+		users = append(users, row.(*user))
+		return nil
+	}, "select name, id from user"))
+	assert.Equal(t, []*user{{1, "张三"}, {2, "李四"}, {3, "王五"}}, users)
+
+	// 回调出错时停止读取
+	backend.setRows([]string{"name"}, []driver.Value{"张三"}, []driver.Value{"李四"}, []driver.Value{"王五"})
+	errStop := errors.New("stop")
+	var names []string
+	assert.Equal(t, errStop, c.QueryStream(new(string), func(row interface{}) error {
+		names = append(names, *row.(*string))
+		if len(names) == 2 {
+			return errStop
+		}
+		return nil
+	}, "select name from user"))
+	assert.Equal(t, []string{"张三", "李四"}, names)
+}
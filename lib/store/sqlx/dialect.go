@@ -0,0 +1,226 @@
+package sqlx
+
+import (
+	"strconv"
+	"strings"
+)
+
+// MySQL 文档建议的不限制行数的 limit 值
+const mysqlMaxRows = "18446744073709551615"
+
+type (
+	// dialect 数据库方言，屏蔽各数据库在连接串、占位符和值转义上的差异
+	dialect interface {
+		// perfectDSN 补全连接字符串
+		perfectDSN(dataSourceName string) string
+		// rebind 将 ? 占位符改写为该数据库的占位符
+		rebind(query string) string
+		// escape 转义字符串值中的特殊字符
+		escape(str string) string
+		// formatBool 布尔值的字面量
+		formatBool(b bool) string
+		// quote 给标识符（表名、列名）加引号
+		quote(ident string) string
+		// limitOffset 分页子句，limit 为 0 表示不限制行数，都为 0 时返回空串
+		limitOffset(limit, offset int) string
+	}
+
+	// mysqlDialect MySQL 方言，也是未指定方言时的默认方言
+	mysqlDialect struct{}
+
+	// postgresDialect Postgres 方言
+	postgresDialect struct{}
+
+	// dialectGetter 可以获取方言的连接
+	dialectGetter interface {
+		getDialect() dialect
+	}
+)
+
+// dialectOf 获取连接或事务会话的方言，无法获取时使用 MySQL 方言
+func dialectOf(s Session) dialect {
+	if getter, ok := s.(dialectGetter); ok {
+		return getter.getDialect()
+	}
+
+	return mysqlDialect{}
+}
+
+func (d mysqlDialect) perfectDSN(dataSourceName string) string {
+	prefectDSN(&dataSourceName)
+	return dataSourceName
+}
+
+func (d mysqlDialect) rebind(query string) string {
+	return query
+}
+
+func (d mysqlDialect) escape(str string) string {
+	return escape(str)
+}
+
+func (d mysqlDialect) formatBool(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+func (d mysqlDialect) quote(ident string) string {
+	return quoteIdent(ident, '`')
+}
+
+// limitOffset MySQL 不支持单独的 offset，以最大行数作为 limit
+func (d mysqlDialect) limitOffset(limit, offset int) string {
+	if limit <= 0 && offset > 0 {
+		return " limit " + mysqlMaxRows + " offset " + strconv.Itoa(offset)
+	}
+
+	return formatLimitOffset(limit, offset)
+}
+
+func (d postgresDialect) perfectDSN(dataSourceName string) string {
+	return dataSourceName
+}
+
+// rebind 将 ? 依次改写为 $1, $2...，引号和注释内的 ? 保持不变。
+// ?? 转义为 ? 本身，用于 JSONB 的 ?、?|、?& 运算符，如 data ?? 'key'、tags ??| array['a']
+func (d postgresDialect) rebind(query string) string {
+	if strings.IndexByte(query, '?') < 0 {
+		return query
+	}
+
+	var b strings.Builder
+	var n int
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case ch == '\'' || ch == '"':
+			end := skipQuoted(query, i)
+			b.WriteString(query[i:end])
+			i = end - 1
+		case strings.HasPrefix(query[i:], "--") || strings.HasPrefix(query[i:], "/*"):
+			end := skipComment(query, i)
+			b.WriteString(query[i:end])
+			i = end - 1
+		case ch == '?' && i+1 < len(query) && query[i+1] == '?':
+			b.WriteByte('?')
+			i++
+		case ch == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		default:
+			b.WriteByte(ch)
+		}
+	}
+
+	return b.String()
+}
+
+// escape Postgres 默认开启 standard_conforming_strings，只需将单引号写两次
+func (d postgresDialect) escape(str string) string {
+	return strings.Replace(str, "'", "''", -1)
+}
+
+func (d postgresDialect) formatBool(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+func (d postgresDialect) quote(ident string) string {
+	return quoteIdent(ident, '"')
+}
+
+func (d postgresDialect) limitOffset(limit, offset int) string {
+	return formatLimitOffset(limit, offset)
+}
+
+func formatLimitOffset(limit, offset int) string {
+	var clause string
+	if limit > 0 {
+		clause = " limit " + strconv.Itoa(limit)
+	}
+	if offset > 0 {
+		clause += " offset " + strconv.Itoa(offset)
+	}
+
+	return clause
+}
+
+// skipQuoted 返回 start 处引号开始的字符串或标识符之后的位置，未闭合时返回末尾，
+// 转义的两个连续引号相当于相邻的两段，同样被整体跳过
+func skipQuoted(query string, start int) int {
+	quote := query[start]
+	for i := start + 1; i < len(query); i++ {
+		if query[i] == quote {
+			return i + 1
+		}
+	}
+
+	return len(query)
+}
+
+// skipComment 返回 start 处注释之后的位置，-- 注释到行尾，/* */ 注释可嵌套，未闭合时返回末尾
+func skipComment(query string, start int) int {
+	if strings.HasPrefix(query[start:], "--") {
+		if pos := strings.IndexByte(query[start:], '\n'); pos >= 0 {
+			return start + pos + 1
+		}
+		return len(query)
+	}
+
+	var depth int
+	for i := start; i < len(query)-1; i++ {
+		switch query[i : i+2] {
+		case "/*":
+			depth++
+			i++
+		case "*/":
+			depth--
+			i++
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+
+	return len(query)
+}
+
+// quoteIdent 给标识符各段加引号，如 user.name 转为 `user`.`name`
+// 整体以反引号包裹的视为单个标识符，按方言换用引号，如 Columns 返回的嵌套列 `addr.city`，
+// 含有空格、括号、运算符等的表达式及其他已加引号的标识符原样返回
+func quoteIdent(ident string, q byte) string {
+	if inner, ok := unquoteIdent(ident); ok {
+		return string(q) + inner + string(q)
+	}
+	if len(ident) == 0 || ident == "*" || strings.ContainsAny(strings.TrimSuffix(ident, ".*"), " ()`\"'*+-/,") {
+		return ident
+	}
+
+	parts := strings.Split(ident, ".")
+	for i, part := range parts {
+		if part != "*" {
+			parts[i] = string(q) + part + string(q)
+		}
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// unquoteIdent 整体以反引号包裹且中间不含反引号时返回去掉反引号的标识符
+func unquoteIdent(ident string) (string, bool) {
+	if len(ident) < 3 || ident[0] != '`' || ident[len(ident)-1] != '`' {
+		return "", false
+	}
+
+	inner := ident[1 : len(ident)-1]
+	if strings.IndexByte(inner, '`') >= 0 {
+		return "", false
+	}
+
+	return inner, true
+}
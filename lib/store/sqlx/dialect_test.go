@@ -0,0 +1,53 @@
+package sqlx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPostgresDialect_Rebind(t *testing.T) {
+	var d postgresDialect
+	assert.Equal(t, "select id from user where id = $1 and name = $2 and note != '?'",
+		d.rebind("select id from user where id = ? and name = ? and note != '?'"))
+	assert.Equal(t, "select 1", d.rebind("select 1"))
+}
+
+func TestPostgresDialect_RebindComment(t *testing.T) {
+	var d postgresDialect
+	assert.Equal(t, "select id -- id = ?\nfrom user where id = $1",
+		d.rebind("select id -- id = ?\nfrom user where id = ?"))
+	assert.Equal(t, "select id /* a = ? /* b = ? */ */ from user where id = $1",
+		d.rebind("select id /* a = ? /* b = ? */ */ from user where id = ?"))
+	assert.Equal(t, "select id from user -- ?", d.rebind("select id from user -- ?"))
+	assert.Equal(t, "select 10 - $1", d.rebind("select 10 - ?"))
+}
+
+func TestPostgresDialect_RebindEscape(t *testing.T) {
+	var d postgresDialect
+	assert.Equal(t, "select id from user where data ? 'k' and id = $1",
+		d.rebind("select id from user where data ?? 'k' and id = ?"))
+	assert.Equal(t, "select id from user where data ?| array['a', 'b'] and data ?& $1",
+		d.rebind("select id from user where data ??| array['a', 'b'] and data ??& ?"))
+}
+
+func TestFormatDialectQuery(t *testing.T) {
+	query := "insert into user(name, valid) values (?, ?)"
+
+	stmt, err := formatDialectQuery(mysqlDialect{}, query, `it's "me"`, true)
+	assert.Nil(t, err)
+	assert.Equal(t, `insert into user(name, valid) values ('it\'s \"me\"', 1)`, stmt)
+
+	stmt, err = formatDialectQuery(postgresDialect{}, query, `it's "me"`, true)
+	assert.Nil(t, err)
+	assert.Equal(t, `insert into user(name, valid) values ('it''s "me"', true)`, stmt)
+
+	_, err = formatDialectQuery(mysqlDialect{}, query, 1)
+	assert.NotNil(t, err)
+}
+
+func TestDialect_PerfectDSN(t *testing.T) {
+	assert.Equal(t, "postgres://root@127.0.0.1/db?sslmode=disable",
+		NewPostgres("postgres://root@127.0.0.1/db?sslmode=disable").(*conn).dataSourceName)
+	assert.Equal(t, "root@tcp(127.0.0.1:3306)/db?parseTime=true&loc=Local",
+		NewMySQL("root@tcp(127.0.0.1:3306)/db").(*conn).dataSourceName)
+}
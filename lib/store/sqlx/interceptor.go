@@ -0,0 +1,59 @@
+package sqlx
+
+import "context"
+
+// 拦截的方法名称
+const (
+	MethodQuery      = "query"
+	MethodExec       = "exec"
+	MethodTxQuery    = "tx.query"
+	MethodTxExec     = "tx.exec"
+	MethodStmtQuery  = "stmt.query"
+	MethodStmtExec   = "stmt.exec"
+	MethodBulkInsert = "bulk.insert"
+)
+
+type (
+	// InvokeFn 执行语句的函数，拦截器可以传入改写后的 query 和 args
+	InvokeFn func(query string, args []interface{}) error
+
+	// Interceptor 语句拦截器，可用于链路追踪、指标统计、SQL审计、语句改写和租户过滤等。
+	// 拦截器需调用 next 继续执行，不调用则语句不会执行；预处理语句已在预处理时确定，改写 query 对其无效
+	Interceptor func(ctx context.Context, method, query string, args []interface{}, next InvokeFn) error
+
+	// 指定拦截方法名称的上下文标记
+	methodCtxKey struct{}
+)
+
+// WithInterceptor 添加语句拦截器，多个拦截器按添加顺序由外向内执行
+func WithInterceptor(interceptor Interceptor) Option {
+	return func(c *conn) {
+		c.interceptors = append(c.interceptors, interceptor)
+	}
+}
+
+// withMethod 返回指定拦截方法名称的上下文，用于 BulkInserter 等内部调用方标明语句来源
+func withMethod(ctx context.Context, method string) context.Context {
+	return context.WithValue(ctx, methodCtxKey{}, method)
+}
+
+// methodOf 取上下文中指定的拦截方法名称，未指定时返回 method
+func methodOf(ctx context.Context, method string) string {
+	if m, ok := ctx.Value(methodCtxKey{}).(string); ok {
+		return m
+	}
+
+	return method
+}
+
+// intercept 依次经过拦截器后执行 invoke
+func intercept(ctx context.Context, interceptors []Interceptor, method, query string, args []interface{},
+	invoke InvokeFn) error {
+	if len(interceptors) == 0 {
+		return invoke(query, args)
+	}
+
+	return interceptors[0](ctx, method, query, args, func(query string, args []interface{}) error {
+		return intercept(ctx, interceptors[1:], method, query, args, invoke)
+	})
+}
@@ -0,0 +1,55 @@
+package sqlx
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithInterceptor(t *testing.T) {
+	const dsn = "interceptor"
+	backend := newMockedBackend(dsn + "?parseTime=true&loc=Local")
+	backend.setRows([]string{"name"}, []driver.Value{"张三"})
+
+	var calls []string
+	trace := func(name string) Interceptor {
+		return func(ctx context.Context, method, query string, args []interface{}, next InvokeFn) error {
+			calls = append(calls, name+":"+method)
+			return next(query, args)
+		}
+	}
+	// 租户过滤：改写语句并追加参数
+	tenant := func(ctx context.Context, method, query string, args []interface{}, next InvokeFn) error {
+		if method == MethodQuery {
+			return next(query+" where tenant_id = ?", append(args, 7))
+		}
+		return next(query, args)
+	}
+	c := NewConn(mockedDriverName, dsn, WithInterceptor(trace("a")), WithInterceptor(trace("b")),
+		WithInterceptor(tenant))
+
+	var name string
+	assert.Nil(t, c.Query(&name, "select name from user"))
+	assert.Equal(t, "张三", name)
+	_, err := c.Exec("delete from user where id = ?", 1)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Transact(func(tx Session) error {
+		_, err := tx.Exec("delete from user where id = ?", 2)
+		return err
+	}))
+	assert.Equal(t, []string{"a:query", "b:query", "a:exec", "b:exec", "a:tx.exec", "b:tx.exec"}, calls)
+	assert.Contains(t, backend.statements(), "select name from user where tenant_id = ?")
+
+	// 拦截器不调用 next 时语句不执行
+	errDenied := errors.New("denied")
+	c = NewConn(mockedDriverName, dsn, WithInterceptor(
+		func(ctx context.Context, method, query string, args []interface{}, next InvokeFn) error {
+			return errDenied
+		}))
+	_, err = c.Exec("drop table user")
+	assert.Equal(t, errDenied, err)
+	assert.NotContains(t, backend.statements(), "drop table user")
+}
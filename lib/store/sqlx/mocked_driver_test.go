@@ -0,0 +1,193 @@
+package sqlx
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+const mockedDriverName = "sqlx_mocked"
+
+var mockedDrivers = new(sync.Map)
+
+func init() {
+	sql.Register(mockedDriverName, mockedDriver{})
+}
+
+type (
+	// mockedDriver 按DSN区分的内存驱动，仅记录语句并返回预置的结果
+	mockedDriver struct{}
+
+	mockedBackend struct {
+		lock     sync.Mutex
+		prepares int32
+		queries  []string
+		columns  []string
+		types    []string
+		rows     [][]driver.Value
+		results  map[string]mockedResultSet
+		err      error
+	}
+
+	// 指定语句的查询结果
+	mockedResultSet struct {
+		columns []string
+		rows    [][]driver.Value
+	}
+
+	mockedDriverConn struct {
+		backend *mockedBackend
+	}
+
+	mockedDriverStmt struct {
+		backend *mockedBackend
+		query   string
+	}
+
+	mockedDriverRows struct {
+		columns []string
+		types   []string
+		rows    [][]driver.Value
+		pos     int
+	}
+)
+
+// newMockedBackend 注册 dsn 对应的内存后端
+func newMockedBackend(dsn string) *mockedBackend {
+	backend := new(mockedBackend)
+	mockedDrivers.Store(dsn, backend)
+	return backend
+}
+
+func (b *mockedBackend) setRows(columns []string, rows ...[]driver.Value) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.columns = columns
+	b.types = nil
+	b.rows = rows
+}
+
+// setRowsFor 设置指定语句的查询结果，其他语句返回 setRows 设置的结果
+func (b *mockedBackend) setRowsFor(query string, columns []string, rows ...[]driver.Value) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.results == nil {
+		b.results = make(map[string]mockedResultSet)
+	}
+	b.results[query] = mockedResultSet{columns: columns, rows: rows}
+}
+
+// setColumnTypes 设置各列的数据库类型名
+func (b *mockedBackend) setColumnTypes(types ...string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.types = types
+}
+
+func (b *mockedBackend) record(query string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.queries = append(b.queries, query)
+}
+
+func (b *mockedBackend) statements() []string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return append([]string(nil), b.queries...)
+}
+
+func (d mockedDriver) Open(name string) (driver.Conn, error) {
+	backend, ok := mockedDrivers.Load(name)
+	if !ok {
+		backend, _ = mockedDrivers.LoadOrStore(name, new(mockedBackend))
+	}
+	return &mockedDriverConn{backend: backend.(*mockedBackend)}, nil
+}
+
+func (c *mockedDriverConn) Prepare(query string) (driver.Stmt, error) {
+	atomic.AddInt32(&c.backend.prepares, 1)
+	return &mockedDriverStmt{backend: c.backend, query: query}, nil
+}
+
+func (c *mockedDriverConn) Close() error {
+	return nil
+}
+
+func (c *mockedDriverConn) Begin() (driver.Tx, error) {
+	c.backend.record("begin")
+	return c, nil
+}
+
+func (c *mockedDriverConn) Commit() error {
+	c.backend.record("commit")
+	return nil
+}
+
+func (c *mockedDriverConn) Rollback() error {
+	c.backend.record("rollback")
+	return nil
+}
+
+func (s *mockedDriverStmt) Close() error {
+	return nil
+}
+
+func (s *mockedDriverStmt) NumInput() int {
+	return -1
+}
+
+func (s *mockedDriverStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.backend.record(s.query)
+	if s.backend.err != nil {
+		return nil, s.backend.err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *mockedDriverStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.backend.record(s.query)
+	if s.backend.err != nil {
+		return nil, s.backend.err
+	}
+
+	s.backend.lock.Lock()
+	defer s.backend.lock.Unlock()
+	if result, ok := s.backend.results[s.query]; ok {
+		return &mockedDriverRows{
+			columns: result.columns,
+			rows:    result.rows,
+		}, nil
+	}
+	return &mockedDriverRows{
+		columns: s.backend.columns,
+		types:   s.backend.types,
+		rows:    s.backend.rows,
+	}, nil
+}
+
+func (r *mockedDriverRows) Columns() []string {
+	return r.columns
+}
+
+func (r *mockedDriverRows) ColumnTypeDatabaseTypeName(index int) string {
+	if index < len(r.types) {
+		return r.types[index]
+	}
+	return ""
+}
+
+func (r *mockedDriverRows) Close() error {
+	return nil
+}
+
+func (r *mockedDriverRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+
+	copy(dest, r.rows[r.pos])
+	r.pos++
+	return nil
+}
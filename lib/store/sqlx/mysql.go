@@ -6,12 +6,14 @@ import (
 )
 
 const (
-	ErrDuplicateEntryCode uint16 = 1062
+	ErrDuplicateEntryCode  uint16 = 1062
+	ErrLockWaitTimeoutCode uint16 = 1205
+	ErrDeadlockCode        uint16 = 1213
 )
 
 // NewMySQL 创建 MySQL 数据库实例
 func NewMySQL(dataSourceName string, opts ...Option) Conn {
-	opts = append(opts, withMySQLAcceptable())
+	opts = append(opts, withMySQLAcceptable(), withMySQLRetryable())
 	return NewConn("mysql", dataSourceName, opts...)
 }
 
@@ -21,6 +23,12 @@ func withMySQLAcceptable() Option {
 	}
 }
 
+func withMySQLRetryable() Option {
+	return func(c *conn) {
+		c.retryable = mysqlRetryable
+	}
+}
+
 func mysqlAcceptable(reqError error) bool {
 	if reqError == nil {
 		return true
@@ -38,3 +46,18 @@ func mysqlAcceptable(reqError error) bool {
 		return false
 	}
 }
+
+// mysqlRetryable 死锁和锁等待超时的事务可以重跑
+func mysqlRetryable(reqError error) bool {
+	sqlError, ok := reqError.(*mysql.MySQLError)
+	if !ok {
+		return false
+	}
+
+	switch sqlError.Number {
+	case ErrDeadlockCode, ErrLockWaitTimeoutCode:
+		return true
+	default:
+		return false
+	}
+}
@@ -1,6 +1,7 @@
 package sqlx
 
 import (
+	"errors"
 	"github.com/go-sql-driver/mysql"
 	"github.com/stretchr/testify/assert"
 	"github.com/z-sdk/goa/lib/breaker"
@@ -26,3 +27,10 @@ func tryOnDuplicateEntryError(t *testing.T, acceptable func(reqError error) bool
 		return &mysql.MySQLError{Number: ErrDuplicateEntryCode}
 	}, c.acceptable)
 }
+
+func TestMySQLRetryable(t *testing.T) {
+	assert.True(t, mysqlRetryable(&mysql.MySQLError{Number: ErrDeadlockCode}))
+	assert.True(t, mysqlRetryable(&mysql.MySQLError{Number: ErrLockWaitTimeoutCode}))
+	assert.False(t, mysqlRetryable(&mysql.MySQLError{Number: ErrDuplicateEntryCode}))
+	assert.False(t, mysqlRetryable(errors.New("any")))
+}
@@ -0,0 +1,153 @@
+package sqlx
+
+import (
+	"errors"
+	"fmt"
+	"github.com/z-sdk/goa/lib/mapping"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+var ErrUnsupportedNamedArg = errors.New("命名参数只支持结构体或 map[string]interface{}")
+
+// bindNamed 将带 :name 命名参数的 SQL 转换为 ? 占位符的 SQL 及对应顺序的参数。
+// arg 为结构体（按 db 标记取列名）或 map[string]interface{}，切片类型的参数会展开为 ?, ?, ? 以便用于 IN (:ids)
+func bindNamed(query string, arg interface{}) (string, []interface{}, error) {
+	values, err := getNamedValues(arg)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var b strings.Builder
+	var args []interface{}
+	runes := []rune(query)
+	var quote rune
+	for i := 0; i < len(runes); i++ {
+		char := runes[i]
+
+		// 引号内的内容原样输出
+		if quote != 0 {
+			b.WriteRune(char)
+			if char == '\\' && i+1 < len(runes) {
+				i++
+				b.WriteRune(runes[i])
+			} else if char == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch {
+		case char == '\'' || char == '"' || char == '`':
+			quote = char
+			b.WriteRune(char)
+		case char == ':' && i+1 < len(runes) && runes[i+1] == ':':
+			// 形如 a::int 的类型转换
+			b.WriteString("::")
+			i++
+		case char == ':' && i+1 < len(runes) && isNameRune(runes[i+1]):
+			start := i + 1
+			end := start
+			for end < len(runes) && isNameRune(runes[end]) {
+				end++
+			}
+
+			name := string(runes[start:end])
+			value, ok := values[name]
+			if !ok {
+				return "", nil, fmt.Errorf("命名参数 %q 未提供", name)
+			}
+
+			placeholders, expanded, ok := expandValue(value)
+			if !ok {
+				return "", nil, fmt.Errorf("命名参数 %q 为空切片", name)
+			}
+			b.WriteString(placeholders)
+			args = append(args, expanded...)
+			i = end - 1
+		default:
+			b.WriteRune(char)
+		}
+	}
+
+	return b.String(), args, nil
+}
+
+// expandValue 将切片参数展开为多个占位符，空切片无法展开时返回 false
+func expandValue(value interface{}) (string, []interface{}, bool) {
+	if value == nil {
+		return "?", []interface{}{nil}, true
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		// []byte 作为单个二进制参数
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return "?", []interface{}{value}, true
+		}
+		if v.Len() == 0 {
+			return "", nil, false
+		}
+
+		args := make([]interface{}, v.Len())
+		for i := range args {
+			args[i] = v.Index(i).Interface()
+		}
+		return strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", "), args, true
+	default:
+		return "?", []interface{}{value}, true
+	}
+}
+
+// getNamedValues 取命名参数的名称——值映射
+func getNamedValues(arg interface{}) (map[string]interface{}, error) {
+	if m, ok := arg.(map[string]interface{}); ok {
+		return m, nil
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(arg))
+	if v.Kind() != reflect.Struct {
+		return nil, ErrUnsupportedNamedArg
+	}
+
+	values := make(map[string]interface{})
+	fillNamedValues(v, values)
+	return values, nil
+}
+
+// fillNamedValues 递归读取结构体字段，列名取自 db 标记，无标记时取字段名
+func fillNamedValues(v reflect.Value, values map[string]interface{}) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		field := v.Field(i)
+		columnName := getColumnName(structField)
+		if columnName == "-" {
+			continue
+		}
+
+		// 无标记的嵌套字段
+		if structField.Anonymous && len(columnName) == 0 &&
+			mapping.Deref(structField.Type).Kind() == reflect.Struct {
+			if field.Kind() == reflect.Ptr && field.IsNil() {
+				continue
+			}
+			fillNamedValues(reflect.Indirect(field), values)
+			continue
+		}
+
+		if !field.CanInterface() {
+			continue
+		}
+		if len(columnName) == 0 {
+			columnName = structField.Name
+		}
+		values[columnName] = field.Interface()
+	}
+}
+
+func isNameRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,52 @@
+package sqlx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBindNamed_Struct(t *testing.T) {
+	type base struct {
+		Id int64 `db:"id"`
+	}
+	type user struct {
+		base
+		Name   string `db:"name"`
+		Status int
+	}
+
+	query, args, err := bindNamed(`update user set name = :name, status = :Status where id = :id and note != 'a:b'`,
+		&user{base: base{Id: 1}, Name: "张三", Status: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, `update user set name = ?, status = ? where id = ? and note != 'a:b'`, query)
+	assert.Equal(t, []interface{}{"张三", 2, int64(1)}, args)
+}
+
+func TestBindNamed_Map(t *testing.T) {
+	query, args, err := bindNamed(`select id from user where id in (:ids) and kind = :kind and data = :data`,
+		map[string]interface{}{
+			"ids":  []int64{1, 2, 3},
+			"kind": 1,
+			"data": []byte("x"),
+		})
+	assert.Nil(t, err)
+	assert.Equal(t, `select id from user where id in (?, ?, ?) and kind = ? and data = ?`, query)
+	assert.Equal(t, []interface{}{int64(1), int64(2), int64(3), 1, []byte("x")}, args)
+
+	// 类型转换不是命名参数
+	query, args, err = bindNamed(`select id::text from user`, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.Equal(t, `select id::text from user`, query)
+	assert.Nil(t, args)
+}
+
+func TestBindNamed_Errors(t *testing.T) {
+	_, _, err := bindNamed(`select id from user where id = :id`, map[string]interface{}{})
+	assert.NotNil(t, err)
+
+	_, _, err = bindNamed(`select id from user where id in (:ids)`, map[string]interface{}{"ids": []int{}})
+	assert.NotNil(t, err)
+
+	_, _, err = bindNamed(`select id from user where id = :id`, 1)
+	assert.Equal(t, ErrUnsupportedNamedArg, err)
+}
@@ -0,0 +1,303 @@
+package sqlx
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	// 游标值的类型
+	cursorInt    = "int"
+	cursorUint   = "uint"
+	cursorFloat  = "float"
+	cursorString = "string"
+	cursorTime   = "time"
+)
+
+var (
+	ErrInvalidPageSize = errors.New("每页条数必须大于 0")
+	ErrInvalidCursor   = errors.New("无效的分页游标")
+
+	// 游标分页的查询语句末尾不能带有的子句，游标条件、排序和 limit 直接追加在语句末尾
+	cursorQueryForbidden = []string{"group", "having", "order", "limit", "union", "for"}
+)
+
+// 游标令牌中保存的上一页最后一行的游标列值
+type cursorToken struct {
+	Kind  string `json:"k"`
+	Value string `json:"v"`
+}
+
+// queryPage 按页码查询，page 从 1 开始，结果写入 dest 并返回总条数
+// query 为不含 limit 的查询语句，总条数由 select count(*) from (query) 查得
+func queryPage(ctx context.Context, s Session, dest interface{}, query string, page, size int,
+	args ...interface{}) (int64, error) {
+	if size <= 0 {
+		return 0, ErrInvalidPageSize
+	}
+	if page < 1 {
+		page = 1
+	}
+	resetSlice(dest)
+
+	var total int64
+	countQuery := fmt.Sprintf("select count(*) from (%s) as t", query)
+	if err := s.QueryCtx(ctx, &total, countQuery, args...); err != nil {
+		return 0, err
+	}
+
+	// 超出总条数的页无需再查
+	offset := (page - 1) * size
+	if int64(offset) >= total {
+		return total, nil
+	}
+
+	pageQuery := query + " limit ? offset ?"
+	pageArgs := append(append([]interface{}(nil), args...), size, offset)
+	if err := s.QueryCtx(ctx, dest, pageQuery, pageArgs...); err != nil && err != ErrNotFound {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+// queryAfter 按游标查询 cursor 之后的 size 行，结果写入 dest，返回下一页的游标，没有更多数据时返回空串。
+// 以 cursorColumn 的值定位，避免深分页时扫描 offset 行：
+// cursorColumn 须唯一且在 query 的查询列中，可带 desc 后缀表示倒序，首页 cursor 传空串。
+// 游标条件、排序和 limit 直接追加到 query 上以便使用索引，因此 query 只能是 select ... from ... [where ...]，
+// 不能带有 group by、having、order by、limit、union 等子句
+func queryAfter(ctx context.Context, s Session, dest interface{}, query, cursorColumn, cursor string, size int,
+	args ...interface{}) (string, error) {
+	if size <= 0 {
+		return "", ErrInvalidPageSize
+	}
+	// 扫描结果追加到切片上，先清空以免复用的切片中的旧行影响末页判断和游标
+	resetSlice(dest)
+
+	column, desc := parseCursorColumn(cursorColumn)
+	op, order := ">", "asc"
+	if desc {
+		op, order = "<", "desc"
+	}
+
+	keywords := indexTopLevelKeywords(query)
+	for _, keyword := range cursorQueryForbidden {
+		if _, ok := keywords[keyword]; ok {
+			return "", fmt.Errorf("游标分页的查询语句不能带有 %s 子句: %q", keyword, query)
+		}
+	}
+
+	quoted := dialectOf(s).quote(column)
+	var b strings.Builder
+	queryArgs := append([]interface{}(nil), args...)
+	if len(cursor) > 0 {
+		value, err := decodeCursor(cursor)
+		if err != nil {
+			return "", err
+		}
+
+		cond := fmt.Sprintf("%s %s ?", quoted, op)
+		if pos, ok := keywords["where"]; ok {
+			// 原条件加括号，以免其中的 or 改变优先级
+			where := pos + len("where")
+			b.WriteString(query[:where])
+			b.WriteString(" (")
+			b.WriteString(strings.TrimSpace(query[where:]))
+			b.WriteString(") and ")
+			b.WriteString(cond)
+		} else {
+			b.WriteString(strings.TrimSpace(query))
+			b.WriteString(" where ")
+			b.WriteString(cond)
+		}
+		queryArgs = append(queryArgs, value)
+	} else {
+		b.WriteString(strings.TrimSpace(query))
+	}
+	b.WriteString(fmt.Sprintf(" order by %s %s limit ?", quoted, order))
+	queryArgs = append(queryArgs, size)
+
+	if err := s.QueryCtx(ctx, dest, b.String(), queryArgs...); err != nil {
+		if err == ErrNotFound {
+			return "", nil
+		}
+		return "", err
+	}
+
+	// 不足一页说明没有更多数据
+	rows := reflect.Indirect(reflect.ValueOf(dest))
+	if rows.Kind() != reflect.Slice || rows.Len() < size {
+		return "", nil
+	}
+
+	// 结果中的列名不带表名前缀
+	value, err := getCursorValue(rows.Index(rows.Len()-1), column[strings.LastIndexByte(column, '.')+1:])
+	if err != nil {
+		return "", err
+	}
+
+	return encodeCursor(value)
+}
+
+// resetSlice dest 为切片指针时将切片长度置 0，保留底层数组以便复用
+func resetSlice(dest interface{}) {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return
+	}
+
+	rv = rv.Elem()
+	if rv.Kind() == reflect.Slice && rv.CanSet() {
+		rv.SetLen(0)
+	}
+}
+
+// parseCursorColumn 解析游标列，如 id desc
+func parseCursorColumn(cursorColumn string) (string, bool) {
+	fields := strings.Fields(cursorColumn)
+	if len(fields) == 2 && strings.EqualFold(fields[1], "desc") {
+		return fields[0], true
+	}
+
+	return strings.TrimSpace(cursorColumn), false
+}
+
+// indexTopLevelKeywords 返回 query 中括号和引号之外各单词首次出现的位置，单词转为小写
+func indexTopLevelKeywords(query string) map[string]int {
+	keywords := make(map[string]int)
+	lower := strings.ToLower(query)
+	var depth int
+	var quote byte
+	for i := 0; i < len(lower); i++ {
+		ch := lower[i]
+		if quote != 0 {
+			if ch == '\\' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch {
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+		case depth == 0 && 'a' <= ch && ch <= 'z' && (i == 0 || !isNameByte(lower[i-1])):
+			end := i
+			for end < len(lower) && isNameByte(lower[end]) && lower[end] != '`' {
+				end++
+			}
+			if _, ok := keywords[lower[i:end]]; !ok {
+				keywords[lower[i:end]] = i
+			}
+			i = end - 1
+		}
+	}
+
+	return keywords
+}
+
+// getCursorValue 取一行中游标列的值，行可以是结构体、map 或单列的基础类型
+func getCursorValue(row reflect.Value, column string) (interface{}, error) {
+	row = reflect.Indirect(row)
+	switch {
+	case row.Type() == mapRowType:
+		if value := row.MapIndex(reflect.ValueOf(column)); value.IsValid() {
+			return value.Interface(), nil
+		}
+	case isScalarType(row.Type()):
+		return row.Interface(), nil
+	case row.Kind() == reflect.Struct:
+		info := getStructInfo(row.Type())
+		if idx, ok := info.columns[column]; ok {
+			field := fieldByIndex(row, info.fields[idx].index)
+			return reflect.Indirect(field).Interface(), nil
+		}
+	}
+
+	return nil, fmt.Errorf("结果中没有游标列 %q", column)
+}
+
+// encodeCursor 将游标列值编码为不透明的游标令牌
+func encodeCursor(value interface{}) (string, error) {
+	var token cursorToken
+	switch v := value.(type) {
+	case time.Time:
+		token = cursorToken{Kind: cursorTime, Value: v.Format(time.RFC3339Nano)}
+	case []byte:
+		token = cursorToken{Kind: cursorString, Value: string(v)}
+	case string:
+		token = cursorToken{Kind: cursorString, Value: v}
+	default:
+		rv := reflect.ValueOf(value)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			token = cursorToken{Kind: cursorInt, Value: fmt.Sprint(rv.Int())}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			token = cursorToken{Kind: cursorUint, Value: fmt.Sprint(rv.Uint())}
+		case reflect.Float32, reflect.Float64:
+			token = cursorToken{Kind: cursorFloat, Value: fmt.Sprint(rv.Float())}
+		case reflect.String:
+			token = cursorToken{Kind: cursorString, Value: rv.String()}
+		default:
+			return "", fmt.Errorf("不支持的游标列值类型 %T", value)
+		}
+	}
+
+	data, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor 解码游标令牌为游标列值
+func decodeCursor(cursor string) (interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var token cursorToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var value interface{}
+	switch token.Kind {
+	case cursorInt:
+		var v int64
+		_, err = fmt.Sscan(token.Value, &v)
+		value = v
+	case cursorUint:
+		var v uint64
+		_, err = fmt.Sscan(token.Value, &v)
+		value = v
+	case cursorFloat:
+		var v float64
+		_, err = fmt.Sscan(token.Value, &v)
+		value = v
+	case cursorTime:
+		value, err = time.Parse(time.RFC3339Nano, token.Value)
+	case cursorString:
+		value = token.Value
+	default:
+		return nil, ErrInvalidCursor
+	}
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return value, nil
+}
@@ -0,0 +1,125 @@
+package sqlx
+
+import (
+	"database/sql/driver"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryPage(t *testing.T) {
+	const dsn = "query_page"
+	backend := newMockedBackend(dsn + "?parseTime=true&loc=Local")
+	backend.setRowsFor("select count(*) from (select id, name from user where age > ?) as t",
+		[]string{"count(*)"}, []driver.Value{int64(25)})
+	backend.setRows([]string{"id", "name"}, []driver.Value{int64(11), "张三"}, []driver.Value{int64(12), "李四"})
+	c := NewConn(mockedDriverName, dsn)
+
+	type user struct {
+		Id   int64  `db:"id"`
+		Name string `db:"name"`
+	}
+	var users []user
+	total, err := c.QueryPage(&users, "select id, name from user where age > ?", 2, 10, 18)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(25), total)
+	assert.Equal(t, []user{{11, "张三"}, {12, "李四"}}, users)
+	assert.Contains(t, backend.statements(), "select id, name from user where age > ? limit ? offset ?")
+
+	// 超出总条数的页不再查询，复用的切片中不残留上一页的行
+	total, err = c.QueryPage(&users, "select id, name from user where age > ?", 4, 10, 18)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(25), total)
+	assert.Empty(t, users)
+
+	_, err = c.QueryPage(&users, "select id, name from user", 1, 0)
+	assert.Equal(t, ErrInvalidPageSize, err)
+}
+
+func TestQueryAfter(t *testing.T) {
+	const dsn = "query_after"
+	backend := newMockedBackend(dsn + "?parseTime=true&loc=Local")
+	backend.setRows([]string{"id", "name"}, []driver.Value{int64(1), "张三"}, []driver.Value{int64(2), "李四"})
+	c := NewConn(mockedDriverName, dsn)
+
+	type user struct {
+		Id   int64  `db:"id"`
+		Name string `db:"name"`
+	}
+	var users []user
+	cursor, err := c.QueryAfter(&users, "select id, name from user", "id", "", 2)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, cursor)
+	assert.Contains(t, backend.statements(), "select id, name from user order by `id` asc limit ?")
+
+	value, err := decodeCursor(cursor)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), value)
+
+	// 不足一页时没有下一页，复用的切片先清空，不把上一页的行计入本页
+	cursor, err = c.QueryAfter(&users, "select id, name from user", "id desc", cursor, 3)
+	assert.Nil(t, err)
+	assert.Empty(t, cursor)
+	assert.Equal(t, []user{{1, "张三"}, {2, "李四"}}, users)
+	assert.Contains(t, backend.statements(), "select id, name from user where `id` < ? order by `id` desc limit ?")
+
+	// 已有条件时加括号后以 and 追加游标条件，带表名的游标列从结果中按列名取值
+	cursor, err = encodeCursor(int64(10))
+	assert.Nil(t, err)
+	cursor, err = c.QueryAfter(&users, "select u.id, u.name from user u where u.age > ? or u.vip = 1",
+		"u.id", cursor, 2, 18)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, cursor)
+	assert.Contains(t, backend.statements(),
+		"select u.id, u.name from user u where (u.age > ? or u.vip = 1) and `u`.`id` > ? order by `u`.`id` asc limit ?")
+
+	_, err = c.QueryAfter(&users, "select id, name from user", "id", "not a cursor", 2)
+	assert.Equal(t, ErrInvalidCursor, err)
+
+	// 不能合并游标条件的查询
+	for _, query := range []string{
+		"select age, count(*) as id from user group by age",
+		"select distinct id from user order by id",
+		"select id from user limit 10",
+		"select id from a union select id from b",
+	} {
+		_, err = c.QueryAfter(&users, query, "id", "", 2)
+		assert.NotNil(t, err, query)
+	}
+	// 子查询中的子句不受限制
+	_, err = c.QueryAfter(&users, "select id, name from user where id in (select user_id from post limit 10)", "id", "", 2)
+	assert.Nil(t, err)
+}
+
+func TestIndexTopLevelKeywords(t *testing.T) {
+	const query = "SELECT `order`, 'limit' FROM t WHERE a IN (SELECT b FROM c GROUP BY b)"
+	keywords := indexTopLevelKeywords(query)
+	assert.Equal(t, 0, keywords["select"])
+	assert.Equal(t, strings.Index(query, "FROM"), keywords["from"])
+	assert.Equal(t, strings.Index(query, "WHERE"), keywords["where"])
+	_, ok := keywords["group"]
+	assert.False(t, ok)
+	_, ok = keywords["order"]
+	assert.False(t, ok)
+	_, ok = keywords["limit"]
+	assert.False(t, ok)
+}
+
+func TestCursorToken(t *testing.T) {
+	now := time.Date(2020, 8, 1, 12, 0, 0, 123, time.UTC)
+	for _, value := range []interface{}{int64(-1), uint64(1), 1.5, "张三", now} {
+		cursor, err := encodeCursor(value)
+		assert.Nil(t, err)
+		decoded, err := decodeCursor(cursor)
+		assert.Nil(t, err)
+		if tm, ok := value.(time.Time); ok {
+			assert.True(t, tm.Equal(decoded.(time.Time)))
+		} else {
+			assert.Equal(t, value, decoded)
+		}
+	}
+
+	_, err := encodeCursor(struct{}{})
+	assert.NotNil(t, err)
+}
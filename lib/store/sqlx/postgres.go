@@ -0,0 +1,67 @@
+package sqlx
+
+import "github.com/lib/pq"
+
+const (
+	ErrUniqueViolationCode      pq.ErrorCode = "23505"
+	ErrSerializationFailureCode pq.ErrorCode = "40001"
+	ErrDeadlockDetectedCode     pq.ErrorCode = "40P01"
+)
+
+// NewPostgres 创建 Postgres 数据库实例
+func NewPostgres(dataSourceName string, opts ...Option) Conn {
+	opts = append(opts, withPostgresDialect(), withPostgresAcceptable(), withPostgresRetryable())
+	return NewConn("postgres", dataSourceName, opts...)
+}
+
+func withPostgresDialect() Option {
+	return func(c *conn) {
+		c.dialect = postgresDialect{}
+	}
+}
+
+func withPostgresAcceptable() Option {
+	return func(c *conn) {
+		c.accept = postgresAcceptable
+	}
+}
+
+func withPostgresRetryable() Option {
+	return func(c *conn) {
+		c.retryable = postgresRetryable
+	}
+}
+
+// postgresAcceptable 唯一约束冲突和串行化失败是业务层面的错误，不计入断路器的失败次数
+func postgresAcceptable(reqError error) bool {
+	if reqError == nil {
+		return true
+	}
+
+	sqlError, ok := reqError.(*pq.Error)
+	if !ok {
+		return false
+	}
+
+	switch sqlError.Code {
+	case ErrUniqueViolationCode, ErrSerializationFailureCode:
+		return true
+	default:
+		return false
+	}
+}
+
+// postgresRetryable 串行化失败和死锁的事务可以重跑
+func postgresRetryable(reqError error) bool {
+	sqlError, ok := reqError.(*pq.Error)
+	if !ok {
+		return false
+	}
+
+	switch sqlError.Code {
+	case ErrSerializationFailureCode, ErrDeadlockDetectedCode:
+		return true
+	default:
+		return false
+	}
+}
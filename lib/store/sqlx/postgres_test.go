@@ -0,0 +1,32 @@
+package sqlx
+
+import (
+	"errors"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/z-sdk/goa/lib/breaker"
+	"testing"
+)
+
+func TestBreakerOnUniqueViolation(t *testing.T) {
+	c := &conn{
+		brk:    breaker.NewBreaker(),
+		accept: postgresAcceptable,
+	}
+	for i := 0; i < 100; i++ {
+		assert.NotNil(t, c.brk.DoWithAcceptable(func() error {
+			return &pq.Error{Code: ErrUniqueViolationCode}
+		}, c.acceptable))
+	}
+	err := c.brk.DoWithAcceptable(func() error {
+		return &pq.Error{Code: ErrUniqueViolationCode}
+	}, c.acceptable)
+	assert.Equal(t, ErrUniqueViolationCode, err.(*pq.Error).Code)
+}
+
+func TestPostgresRetryable(t *testing.T) {
+	assert.True(t, postgresRetryable(&pq.Error{Code: ErrSerializationFailureCode}))
+	assert.True(t, postgresRetryable(&pq.Error{Code: ErrDeadlockDetectedCode}))
+	assert.False(t, postgresRetryable(&pq.Error{Code: ErrUniqueViolationCode}))
+	assert.False(t, postgresRetryable(errors.New("any")))
+}
@@ -0,0 +1,292 @@
+package sqlxtest
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/z-sdk/goa/lib/store/sqlx"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	kindQuery = "query"
+	kindExec  = "exec"
+)
+
+// AnyArg 匹配任意参数值，用于 time.Now() 等无法预知的参数
+var AnyArg interface{} = anyArg{}
+
+// 数据源序号，保证每个测试连接独占一个连接池
+var dsnSeq uint64
+
+type (
+	// Conn 不依赖真实数据库的 sqlx.Conn 测试替身。
+	// 语句经 sqlx 的真实连接逻辑（拦截器、事务、结果扫描）发往内存驱动，由预先登记的预期应答，
+	// 预期与语句顺序无关，每条预期默认只匹配一次。
+	// 事务的 begin、commit、rollback 及保存点语句无需登记预期，但登记后同样参与匹配
+	Conn struct {
+		sqlx.Conn
+		dsn          string
+		lock         sync.Mutex
+		expectations []*Expectation
+		statements   []Statement
+		unexpected   []Statement
+	}
+
+	// Statement 已执行的语句及其驱动参数
+	Statement struct {
+		Query string
+		Args  []interface{}
+	}
+
+	// Expectation 预期的语句及其应答
+	Expectation struct {
+		kind    string
+		query   string
+		args    []interface{} // nil 表示不校验参数
+		columns []string
+		rows    [][]driver.Value
+		result  driver.Result
+		err     error
+		times   int
+		calls   int
+	}
+
+	anyArg struct{}
+)
+
+// NewConn 新建测试连接，opts 为 sqlx 的连接选项，如拦截器，用完需调用 Close
+func NewConn(opts ...sqlx.Option) *Conn {
+	c := &Conn{
+		dsn: fmt.Sprintf("%s-%d", driverName, atomic.AddUint64(&dsnSeq, 1)),
+	}
+	backends.Store(c.dsn, c)
+	c.Conn = sqlx.NewConn(driverName, c.dsn, opts...)
+
+	return c
+}
+
+// Close 注销测试连接并关闭其连接池，可重复调用
+func (c *Conn) Close() error {
+	backends.Delete(c.dsn)
+	if closer, ok := c.Conn.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+// ExpectQuery 登记预期的查询语句，语句按空白归一后精确匹配，默认返回空结果集
+func (c *Conn) ExpectQuery(query string) *Expectation {
+	return c.expect(kindQuery, query)
+}
+
+// ExpectExec 登记预期的执行语句，默认返回影响 1 行的结果
+func (c *Conn) ExpectExec(query string) *Expectation {
+	e := c.expect(kindExec, query)
+	e.result = fakeResult{rowsAffected: 1}
+	return e
+}
+
+// ExpectBegin 登记预期的开启事务
+func (c *Conn) ExpectBegin() *Expectation {
+	return c.expect(kindExec, "begin")
+}
+
+// ExpectCommit 登记预期的提交事务
+func (c *Conn) ExpectCommit() *Expectation {
+	return c.expect(kindExec, "commit")
+}
+
+// ExpectRollback 登记预期的回滚事务
+func (c *Conn) ExpectRollback() *Expectation {
+	return c.expect(kindExec, "rollback")
+}
+
+// Statements 返回已执行的全部语句，含事务语句
+func (c *Conn) Statements() []Statement {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return append([]Statement(nil), c.statements...)
+}
+
+// ExpectationsWereMet 检查是否所有预期均已满足且没有未预期的语句
+func (c *Conn) ExpectationsWereMet() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var msgs []string
+	for _, e := range c.expectations {
+		if e.calls < e.times {
+			msgs = append(msgs, fmt.Sprintf("预期的%s未满足(%d/%d): %s", e.kind, e.calls, e.times, e.query))
+		}
+	}
+	for _, s := range c.unexpected {
+		msgs = append(msgs, fmt.Sprintf("未预期的语句: %s %v", s.Query, s.Args))
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	return errors.New(strings.Join(msgs, "\n"))
+}
+
+// AssertExpectations 预期未满足时标记测试失败
+func (c *Conn) AssertExpectations(t testing.TB) {
+	t.Helper()
+	if err := c.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func (c *Conn) expect(kind, query string) *Expectation {
+	e := &Expectation{
+		kind:  kind,
+		query: normalize(query),
+		times: 1,
+	}
+
+	c.lock.Lock()
+	c.expectations = append(c.expectations, e)
+	c.lock.Unlock()
+
+	return e
+}
+
+// handle 记录语句并查找匹配的预期，无需预期的事务语句未匹配时返回 nil
+func (c *Conn) handle(kind, query string, args []driver.Value) (*Expectation, error) {
+	query = normalize(query)
+	statement := Statement{Query: query}
+	for _, arg := range args {
+		statement.Args = append(statement.Args, arg)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.statements = append(c.statements, statement)
+	for _, e := range c.expectations {
+		if e.calls < e.times && e.match(kind, query, args) {
+			e.calls++
+			return e, e.err
+		}
+	}
+
+	if kind == kindExec && isTxControl(query) {
+		return nil, nil
+	}
+
+	c.unexpected = append(c.unexpected, statement)
+	return nil, fmt.Errorf("sqlxtest: 未预期的语句 %s %v", query, statement.Args)
+}
+
+// WithArgs 指定预期的参数，可用 AnyArg 匹配任意值
+func (e *Expectation) WithArgs(args ...interface{}) *Expectation {
+	e.args = make([]interface{}, len(args))
+	for i, arg := range args {
+		e.args[i] = toDriverValue(arg)
+	}
+	return e
+}
+
+// WillReturnRows 指定查询返回的列和行，由 sqlx 按真实逻辑扫描
+func (e *Expectation) WillReturnRows(columns []string, rows ...[]interface{}) *Expectation {
+	e.columns = columns
+	e.rows = make([][]driver.Value, len(rows))
+	for i, row := range rows {
+		values := make([]driver.Value, len(row))
+		for j, value := range row {
+			values[j] = toDriverValue(value)
+		}
+		e.rows[i] = values
+	}
+	return e
+}
+
+// WillReturnResult 指定执行返回的自增 id 和影响行数
+func (e *Expectation) WillReturnResult(lastInsertId, rowsAffected int64) *Expectation {
+	e.result = fakeResult{lastInsertId: lastInsertId, rowsAffected: rowsAffected}
+	return e
+}
+
+// WillReturnError 指定语句返回的错误
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+// Times 指定预期可匹配的次数
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+func (e *Expectation) match(kind, query string, args []driver.Value) bool {
+	if e.kind != kind || e.query != query {
+		return false
+	}
+	if e.args == nil {
+		return true
+	}
+	if len(e.args) != len(args) {
+		return false
+	}
+
+	for i, arg := range args {
+		if !matchArg(e.args[i], arg) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matchArg(expected interface{}, actual driver.Value) bool {
+	switch v := expected.(type) {
+	case anyArg:
+		return true
+	case time.Time:
+		t, ok := actual.(time.Time)
+		return ok && v.Equal(t)
+	default:
+		return reflect.DeepEqual(expected, actual)
+	}
+}
+
+// toDriverValue 按 database/sql 的默认规则转换参数，使其与驱动收到的值可比较
+func toDriverValue(value interface{}) interface{} {
+	if _, ok := value.(anyArg); ok {
+		return value
+	}
+
+	v, err := driver.DefaultParameterConverter.ConvertValue(value)
+	if err != nil {
+		return value
+	}
+
+	return v
+}
+
+// isTxControl 是否为事务控制语句
+func isTxControl(query string) bool {
+	switch query {
+	case "begin", "commit", "rollback":
+		return true
+	}
+
+	return strings.HasPrefix(query, "savepoint ") ||
+		strings.HasPrefix(query, "release savepoint ") ||
+		strings.HasPrefix(query, "rollback to savepoint ")
+}
+
+// normalize 将连续空白归一为单个空格，便于多行语句的匹配
+func normalize(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
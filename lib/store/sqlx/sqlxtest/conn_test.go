@@ -0,0 +1,151 @@
+package sqlxtest
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/z-sdk/goa/lib/store/sqlx"
+	"testing"
+	"time"
+)
+
+type user struct {
+	Id   int64  `db:"id"`
+	Name string `db:"name"`
+}
+
+func TestConn_Query(t *testing.T) {
+	conn := NewConn()
+	defer conn.Close()
+	conn.ExpectQuery("select id, name from user where id = ?").
+		WithArgs(1).
+		WillReturnRows([]string{"id", "name"}, []interface{}{1, "kevin"})
+	conn.ExpectQuery("select id, name from user").
+		WillReturnRows([]string{"id", "name"}, []interface{}{1, "kevin"}, []interface{}{2, "jack"})
+
+	var u user
+	assert.Nil(t, conn.Query(&u, "select id, name\n\tfrom user where id = ?", 1))
+	assert.Equal(t, user{Id: 1, Name: "kevin"}, u)
+
+	var users []user
+	assert.Nil(t, conn.Query(&users, "select id, name from user"))
+	assert.Equal(t, []user{{Id: 1, Name: "kevin"}, {Id: 2, Name: "jack"}}, users)
+	conn.AssertExpectations(t)
+
+	// 空结果集按真实逻辑返回 ErrNotFound
+	conn.ExpectQuery("select id, name from user where id = ?").WithArgs(2)
+	assert.Equal(t, sqlx.ErrNotFound, conn.Query(&u, "select id, name from user where id = ?", 2))
+	conn.AssertExpectations(t)
+}
+
+func TestConn_Exec(t *testing.T) {
+	conn := NewConn()
+	defer conn.Close()
+	conn.ExpectExec("update user set updated_at = ? where id = ?").
+		WithArgs(AnyArg, 1).
+		WillReturnResult(0, 0)
+	conn.ExpectExec("insert into user (name) values (?)").
+		WithArgs("kevin").
+		WillReturnResult(3, 1)
+
+	result, err := conn.Exec("update user set updated_at = ? where id = ?", time.Now(), 1)
+	assert.Nil(t, err)
+	affected, _ := result.RowsAffected()
+	assert.Equal(t, int64(0), affected)
+
+	result, err = conn.Exec("insert into user (name) values (?)", "kevin")
+	assert.Nil(t, err)
+	id, _ := result.LastInsertId()
+	assert.Equal(t, int64(3), id)
+
+	conn.AssertExpectations(t)
+	assert.Equal(t, []Statement{
+		{Query: "update user set updated_at = ? where id = ?", Args: conn.Statements()[0].Args},
+		{Query: "insert into user (name) values (?)", Args: []interface{}{"kevin"}},
+	}, conn.Statements())
+}
+
+func TestConn_Unexpected(t *testing.T) {
+	conn := NewConn()
+	defer conn.Close()
+	conn.ExpectExec("delete from user where id = ?").WithArgs(1)
+	conn.ExpectQuery("select count(*) from user").WillReturnError(errors.New("boom"))
+
+	_, err := conn.Exec("delete from user where id = ?", 2)
+	assert.NotNil(t, err)
+
+	var count int
+	assert.EqualError(t, conn.Query(&count, "select count(*) from user"), "boom")
+
+	err = conn.ExpectationsWereMet()
+	assert.Contains(t, err.Error(), "预期的exec未满足(0/1): delete from user where id = ?")
+	assert.Contains(t, err.Error(), "未预期的语句: delete from user where id = ? [2]")
+}
+
+func TestConn_Transact(t *testing.T) {
+	conn := NewConn()
+	defer conn.Close()
+	conn.ExpectBegin()
+	conn.ExpectExec("update user set name = ? where id = ?").WithArgs("kevin", 1)
+	conn.ExpectQuery("select name from user where id = ?").
+		WithArgs(1).
+		WillReturnRows([]string{"name"}, []interface{}{"kevin"})
+	conn.ExpectCommit()
+
+	err := conn.Transact(func(session sqlx.Session) error {
+		if _, err := session.Exec("update user set name = ? where id = ?", "kevin", 1); err != nil {
+			return err
+		}
+
+		var name string
+		if err := session.Query(&name, "select name from user where id = ?", 1); err != nil {
+			return err
+		}
+		assert.Equal(t, "kevin", name)
+
+		// 嵌套事务的保存点语句无需登记预期
+		return session.Transact(func(session sqlx.Session) error {
+			return nil
+		})
+	})
+	assert.Nil(t, err)
+	conn.AssertExpectations(t)
+
+	// 事务内未满足的预期导致回滚
+	conn.ExpectRollback()
+	err = conn.Transact(func(session sqlx.Session) error {
+		_, err := session.Exec("delete from user")
+		return err
+	})
+	assert.NotNil(t, err)
+
+	var queries []string
+	for _, statement := range conn.Statements() {
+		queries = append(queries, statement.Query)
+	}
+	assert.Equal(t, []string{
+		"begin",
+		"update user set name = ? where id = ?",
+		"select name from user where id = ?",
+		"savepoint sqlx_sp_1",
+		"release savepoint sqlx_sp_1",
+		"commit",
+		"begin",
+		"delete from user",
+		"rollback",
+	}, queries)
+	assert.EqualError(t, conn.ExpectationsWereMet(), "未预期的语句: delete from user []")
+}
+
+func TestConn_Close(t *testing.T) {
+	conn := NewConn()
+	conn.ExpectQuery("select 1").WillReturnRows([]string{"1"}, []interface{}{1})
+	var one int
+	assert.Nil(t, conn.Query(&one, "select 1"))
+
+	// 关闭后注销数据源，再次使用时无法连接
+	assert.Nil(t, conn.Close())
+	_, ok := backends.Load(conn.dsn)
+	assert.False(t, ok)
+	assert.NotNil(t, conn.Query(&one, "select 1"))
+	assert.Nil(t, conn.Close())
+}
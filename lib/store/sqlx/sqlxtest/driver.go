@@ -0,0 +1,149 @@
+package sqlxtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// 测试驱动名称
+const driverName = "sqlxtest"
+
+// 按数据源名称登记的测试连接
+var backends sync.Map
+
+func init() {
+	sql.Register(driverName, fakeDriver{})
+}
+
+type (
+	// fakeDriver 内存驱动，语句交由数据源对应的测试连接应答
+	fakeDriver struct{}
+
+	fakeConn struct {
+		backend *Conn
+	}
+
+	fakeStmt struct {
+		backend *Conn
+		query   string
+	}
+
+	fakeRows struct {
+		columns []string
+		rows    [][]driver.Value
+		pos     int
+	}
+)
+
+func (d fakeDriver) Open(name string) (driver.Conn, error) {
+	// 去掉方言补全的连接参数
+	if pos := strings.IndexByte(name, '?'); pos >= 0 {
+		name = name[:pos]
+	}
+
+	backend, ok := backends.Load(name)
+	if !ok {
+		return nil, fmt.Errorf("sqlxtest: 未知的数据源 %s", name)
+	}
+
+	return &fakeConn{backend: backend.(*Conn)}, nil
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{backend: c.backend, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if _, err := c.backend.handle(kindExec, "begin", nil); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	_, err := c.backend.handle(kindExec, "commit", nil)
+	return err
+}
+
+func (c *fakeConn) Rollback() error {
+	_, err := c.backend.handle(kindExec, "rollback", nil)
+	return err
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	expectation, err := s.backend.handle(kindExec, s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	if expectation == nil {
+		return driver.RowsAffected(0), nil
+	}
+
+	return expectation.result, nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	expectation, err := s.backend.handle(kindQuery, s.query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fakeRows{
+		columns: expectation.columns,
+		rows:    expectation.rows,
+	}, nil
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+
+	copy(dest, r.rows[r.pos])
+	r.pos++
+	return nil
+}
+
+// fakeResult 执行结果
+type fakeResult struct {
+	lastInsertId int64
+	rowsAffected int64
+}
+
+func (r fakeResult) LastInsertId() (int64, error) {
+	return r.lastInsertId, nil
+}
+
+func (r fakeResult) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
@@ -1,61 +1,231 @@
 package sqlx
 
 import (
+	"context"
 	"database/sql"
 	"github.com/z-sdk/goa/lib/logx"
+	"strconv"
+	"strings"
 	"time"
 )
 
-func doQuery(db session, scanner func(*sql.Rows) error, query string, args ...interface{}) error {
-	// 格式化后的查询字符串
-	stmt, err := formatQuery(query, args...)
-	if err != nil {
-		return err
+// 预处理语句会话
+type statement struct {
+	conn  *conn
+	query string
+	stmt  *sql.Stmt
+}
+
+func (s statement) Close() error {
+	// 缓存的预处理语句由缓存统一关闭
+	if s.cached() {
+		return nil
 	}
 
-	// 带有慢查询检测
-	startTime := time.Now()
-	rows, err := db.Query(query, args...)
-	duration := time.Since(startTime)
+	return s.stmt.Close()
+}
 
-	if duration > slowThreshold {
-		logx.WithDuration(duration).Slowf("[SQL] 慢查询 - %s", stmt)
-	} else {
-		logx.WithDuration(duration).Infof("[SQL] 查询: %s", stmt)
-	}
+func (s statement) Query(dest interface{}, args ...interface{}) error {
+	return s.QueryCtx(context.Background(), dest, args...)
+}
 
-	if err != nil {
-		logSqlError(stmt, err)
+func (s statement) QueryCtx(ctx context.Context, dest interface{}, args ...interface{}) error {
+	var scanError error
+	return s.conn.brk.DoWithAcceptable(func() error {
+		stmt, release, err := s.getStmt(ctx)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		return doStmtQuery(ctx, s.conn, stmt, func(rows *sql.Rows) (int64, error) {
+			n, err := scan(rows, dest)
+			scanError = err
+			return n, err
+		}, s.query, args...)
+	}, func(reqError error) bool {
+		return reqError == scanError || s.conn.acceptableCtx(ctx, reqError)
+	})
+}
+
+func (s statement) Exec(args ...interface{}) (sql.Result, error) {
+	return s.ExecCtx(context.Background(), args...)
+}
+
+func (s statement) ExecCtx(ctx context.Context, args ...interface{}) (result sql.Result, err error) {
+	err = s.conn.brk.DoWithAcceptable(func() error {
+		stmt, release, err := s.getStmt(ctx)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		result, err = doStmtExec(ctx, s.conn, stmt, s.query, args...)
 		return err
-	}
-	defer func() {
-		_ = rows.Close()
-	}()
+	}, func(reqError error) bool {
+		return s.conn.acceptableCtx(ctx, reqError)
+	})
+	return
+}
 
-	return scanner(rows)
+func (s statement) cached() bool {
+	return s.conn.stmtCacheSize > 0
 }
 
-func doExec(db session, query string, args ...interface{}) (sql.Result, error) {
-	// 格式化后的查询字符串
-	stmt, err := formatQuery(query, args...)
-	if err != nil {
-		return nil, err
+// getStmt 缓存模式下每次都从缓存中取，以免持有已被淘汰关闭的语句，执行完须调用 release
+func (s statement) getStmt(ctx context.Context) (stmt *sql.Stmt, release func(), err error) {
+	if s.cached() {
+		return s.conn.prepare(ctx, s.query)
 	}
 
-	// 带有慢查询检测
-	startTime := time.Now()
-	result, err := db.Exec(query, args...)
-	duration := time.Since(startTime)
+	return s.stmt, func() {}, nil
+}
+
+func doQuery(ctx context.Context, c *conn, db session, method string, scanner func(*sql.Rows) (int64, error),
+	query string, args ...interface{}) error {
+	return intercept(ctx, c.interceptors, method, query, args, func(query string, args []interface{}) error {
+		// 格式化后的查询字符串
+		stmt, err := formatDialectQuery(c.dialect, query, args...)
+		if err != nil {
+			return err
+		}
+
+		// 带有慢查询检测
+		startTime := time.Now()
+		rows, err := db.QueryContext(ctx, c.rebind(query, args), args...)
+		duration := time.Since(startTime)
+		if err != nil {
+			c.logStmt(method, duration, -1, stmt)
+			logSqlError(stmt, err)
+			return err
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+
+		// 扫描完才知道返回的行数，故在扫描后记录日志，耗时仍只计查询本身
+		n, err := scanner(rows)
+		c.logStmt(method, duration, n, stmt)
+		return err
+	})
+}
+
+func doExec(ctx context.Context, c *conn, db session, method string, query string, args ...interface{}) (
+	result sql.Result, err error) {
+	err = intercept(ctx, c.interceptors, method, query, args, func(query string, args []interface{}) error {
+		// 格式化后的查询字符串
+		stmt, err := formatDialectQuery(c.dialect, query, args...)
+		if err != nil {
+			return err
+		}
 
-	if duration > slowThreshold {
-		logx.WithDuration(duration).Slowf("[SQL] 慢执行(%v) - %s", duration, stmt)
+		// 带有慢查询检测
+		startTime := time.Now()
+		result, err = db.ExecContext(ctx, c.rebind(query, args), args...)
+		c.logStmt(method, time.Since(startTime), rowsAffected(result, err), stmt)
+
+		if err != nil {
+			logSqlError(stmt, err)
+		}
+
+		return err
+	})
+	return
+}
+
+func doStmtQuery(ctx context.Context, c *conn, stmt *sql.Stmt, scanner func(*sql.Rows) (int64, error),
+	query string, args ...interface{}) error {
+	return intercept(ctx, c.interceptors, MethodStmtQuery, query, args, func(_ string, args []interface{}) error {
+		// 格式化后的查询字符串
+		stmtStr, err := formatDialectQuery(c.dialect, query, args...)
+		if err != nil {
+			return err
+		}
+
+		// 带有慢查询检测
+		startTime := time.Now()
+		rows, err := stmt.QueryContext(ctx, args...)
+		duration := time.Since(startTime)
+		if err != nil {
+			c.logStmt(MethodStmtQuery, duration, -1, stmtStr)
+			logSqlError(stmtStr, err)
+			return err
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+
+		n, err := scanner(rows)
+		c.logStmt(MethodStmtQuery, duration, n, stmtStr)
+		return err
+	})
+}
+
+func doStmtExec(ctx context.Context, c *conn, stmt *sql.Stmt, query string, args ...interface{}) (
+	result sql.Result, err error) {
+	err = intercept(ctx, c.interceptors, MethodStmtExec, query, args, func(_ string, args []interface{}) error {
+		// 格式化后的查询字符串
+		stmtStr, err := formatDialectQuery(c.dialect, query, args...)
+		if err != nil {
+			return err
+		}
+
+		// 带有慢查询检测
+		startTime := time.Now()
+		result, err = stmt.ExecContext(ctx, args...)
+		c.logStmt(MethodStmtExec, time.Since(startTime), rowsAffected(result, err), stmtStr)
+
+		if err != nil {
+			logSqlError(stmtStr, err)
+		}
+
+		return err
+	})
+	return
+}
+
+// logStmt 记录语句日志，kind 为拦截的方法名称：慢语句总是记入慢日志，开启全量语句日志时其余语句记入信息日志
+func (c *conn) logStmt(kind string, duration time.Duration, rows int64, stmt string) {
+	slow := duration > c.slowThreshold
+	if !slow && !c.logAllStmts {
+		return
+	}
+
+	content := formatStmtLog(kind, desensitize(c.dataSourceName), rows, stmt)
+	if slow {
+		logx.WithDuration(duration).Slow(content)
 	} else {
-		logx.WithDuration(duration).Infof("[SQL] 执行: %s", stmt)
+		logx.WithDuration(duration).Info(content)
+	}
+}
+
+// formatStmtLog 格式化语句日志内容，形如 [SQL] exec dsn=tcp(127.0.0.1:3306)/db rows=1 stmt=update ...
+// rows 为执行语句的影响行数或查询返回的行数，未知时不输出，stmt 总在最后以便日志分析工具解析
+func formatStmtLog(kind, dsn string, rows int64, stmt string) string {
+	var b strings.Builder
+	b.WriteString("[SQL] ")
+	b.WriteString(kind)
+	b.WriteString(" dsn=")
+	b.WriteString(dsn)
+	if rows >= 0 {
+		b.WriteString(" rows=")
+		b.WriteString(strconv.FormatInt(rows, 10))
+	}
+	b.WriteString(" stmt=")
+	b.WriteString(stmt)
+	return b.String()
+}
+
+// rowsAffected 取执行结果的影响行数，无法获取时返回 -1
+func rowsAffected(result sql.Result, err error) int64 {
+	if err != nil || result == nil {
+		return -1
 	}
 
+	rows, err := result.RowsAffected()
 	if err != nil {
-		logSqlError(stmt, err)
+		return -1
 	}
 
-	return result, err
+	return rows
 }
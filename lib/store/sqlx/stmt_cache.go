@@ -0,0 +1,125 @@
+package sqlx
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"github.com/z-sdk/goa/lib/logx"
+	"sync"
+)
+
+type (
+	// stmtCache 按 SQL 缓存预处理语句的 LRU 缓存，超出容量时淘汰最久未用的语句，
+	// 被淘汰的语句待所有使用者释放后才关闭
+	stmtCache struct {
+		size     int
+		lock     sync.Mutex
+		list     *list.List
+		elements map[string]*list.Element
+	}
+
+	stmtEntry struct {
+		query   string
+		stmt    *sql.Stmt
+		refs    int  // 正在使用该语句的次数
+		evicted bool // 是否已被淘汰
+	}
+)
+
+func newStmtCache(size int) *stmtCache {
+	return &stmtCache{
+		size:     size,
+		list:     list.New(),
+		elements: make(map[string]*list.Element),
+	}
+}
+
+// prepare 获取 query 对应的预处理语句，缓存中没有则向数据库预处理并加入缓存，
+// 用完后须调用返回的 release 释放，以便语句被淘汰后关闭
+func (c *stmtCache) prepare(ctx context.Context, db *sql.DB, query string) (stmt *sql.Stmt, release func(), err error) {
+	if entry, ok := c.get(query); ok {
+		return entry.stmt, c.releaser(entry), nil
+	}
+
+	// 预处理需要访问数据库，不在锁内进行
+	stmt, err = db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// 并发预处理了同一语句，以先入缓存者为准
+	if elem, ok := c.elements[query]; ok {
+		_ = stmt.Close()
+		c.list.MoveToFront(elem)
+		entry := elem.Value.(*stmtEntry)
+		entry.refs++
+		return entry.stmt, c.releaser(entry), nil
+	}
+
+	entry := &stmtEntry{
+		query: query,
+		stmt:  stmt,
+		refs:  1,
+	}
+	c.elements[query] = c.list.PushFront(entry)
+	for c.list.Len() > c.size {
+		c.removeOldest()
+	}
+
+	return stmt, c.releaser(entry), nil
+}
+
+func (c *stmtCache) get(query string) (*stmtEntry, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	elem, ok := c.elements[query]
+	if !ok {
+		return nil, false
+	}
+
+	c.list.MoveToFront(elem)
+	entry := elem.Value.(*stmtEntry)
+	entry.refs++
+	return entry, true
+}
+
+// releaser 返回释放 entry 的函数，重复调用只释放一次
+func (c *stmtCache) releaser(entry *stmtEntry) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.lock.Lock()
+			defer c.lock.Unlock()
+
+			entry.refs--
+			if entry.evicted && entry.refs == 0 {
+				closeStmtEntry(entry)
+			}
+		})
+	}
+}
+
+func (c *stmtCache) removeOldest() {
+	elem := c.list.Back()
+	if elem == nil {
+		return
+	}
+
+	entry := c.list.Remove(elem).(*stmtEntry)
+	delete(c.elements, entry.query)
+	// 仍在使用中的语句由最后一个使用者释放时关闭
+	entry.evicted = true
+	if entry.refs == 0 {
+		closeStmtEntry(entry)
+	}
+}
+
+func closeStmtEntry(entry *stmtEntry) {
+	if err := entry.stmt.Close(); err != nil {
+		logx.Errorf("[SQL] 关闭预处理语句失败: %v >>> %s", err, entry.query)
+	}
+}
@@ -0,0 +1,105 @@
+package sqlx
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPrepare_WithStmtCache(t *testing.T) {
+	const dsn = "stmt_cache"
+	backend := newMockedBackend(dsn + "?parseTime=true&loc=Local")
+	backend.setRows([]string{"id", "name"}, []driver.Value{int64(1), "张三"})
+	c := NewConn(mockedDriverName, dsn, WithStmtCache(2))
+
+	for i := 0; i < 10; i++ {
+		stmt, err := c.Prepare("select id, name from user where id = ?")
+		assert.Nil(t, err)
+
+		var user struct {
+			Id   int64  `db:"id"`
+			Name string `db:"name"`
+		}
+		assert.Nil(t, stmt.Query(&user, 1))
+		assert.Equal(t, int64(1), user.Id)
+		assert.Equal(t, "张三", user.Name)
+		assert.Nil(t, stmt.Close())
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&backend.prepares))
+}
+
+func TestStmtCache_Evict(t *testing.T) {
+	const dsn = "stmt_cache_evict"
+	backend := newMockedBackend(dsn + "?parseTime=true&loc=Local")
+	c := NewConn(mockedDriverName, dsn, WithStmtCache(2))
+
+	for _, query := range []string{"delete from a", "delete from b", "delete from c", "delete from a"} {
+		stmt, err := c.Prepare(query)
+		assert.Nil(t, err)
+		_, err = stmt.Exec()
+		assert.Nil(t, err)
+	}
+
+	// a 被 c 挤出缓存后需重新预处理
+	assert.Equal(t, int32(4), atomic.LoadInt32(&backend.prepares))
+
+	db, err := getPingedConn(mockedDriverName, dsn+"?parseTime=true&loc=Local", PoolConf{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, db.stmts.list.Len())
+}
+
+func TestStmtCache_EvictInUse(t *testing.T) {
+	const dsn = "stmt_cache_in_use"
+	newMockedBackend(dsn + "?parseTime=true&loc=Local")
+	db, err := getPingedConn(mockedDriverName, dsn+"?parseTime=true&loc=Local", PoolConf{})
+	assert.Nil(t, err)
+
+	cache := newStmtCache(1)
+	stmt, release, err := cache.prepare(context.Background(), db.DB, "delete from a")
+	assert.Nil(t, err)
+	_, releaseB, err := cache.prepare(context.Background(), db.DB, "delete from b")
+	assert.Nil(t, err)
+	releaseB()
+
+	// 被淘汰但仍在使用的语句不会关闭
+	_, err = stmt.Exec()
+	assert.Nil(t, err)
+
+	// 最后一个使用者释放后关闭，重复释放无影响
+	release()
+	release()
+	_, err = stmt.Exec()
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, cache.list.Len())
+}
+
+func TestStmtCache_ConcurrentEvict(t *testing.T) {
+	const dsn = "stmt_cache_concurrent"
+	newMockedBackend(dsn + "?parseTime=true&loc=Local")
+	// 拿到语句后稍等再执行，使其他协程有机会在此期间将其淘汰
+	c := NewConn(mockedDriverName, dsn, WithStmtCache(1), WithInterceptor(
+		func(ctx context.Context, method, query string, args []interface{}, next InvokeFn) error {
+			time.Sleep(time.Millisecond)
+			return next(query, args)
+		}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			stmt, err := c.Prepare(fmt.Sprintf("delete from t%d", i%3))
+			assert.Nil(t, err)
+			for j := 0; j < 50; j++ {
+				_, err := stmt.Exec()
+				assert.Nil(t, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
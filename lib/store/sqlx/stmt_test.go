@@ -0,0 +1,37 @@
+package sqlx
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFormatStmtLog(t *testing.T) {
+	dsn := desensitize("root:asdfasdf@tcp(127.0.0.1:3306)/nest_label?parseTime=true")
+	stmt, err := formatDialectQuery(mysqlDialect{}, "update user set name = ? where id = ?", "张三", 1)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "[SQL] exec dsn=tcp(127.0.0.1:3306)/nest_label?parseTime=true rows=2 "+
+		"stmt=update user set name = '张三' where id = 1", formatStmtLog("exec", dsn, 2, stmt))
+	assert.Equal(t, "[SQL] query dsn=tcp(127.0.0.1:3306)/nest_label?parseTime=true stmt=select 1",
+		formatStmtLog("query", dsn, -1, "select 1"))
+}
+
+type mockedResult struct {
+	rows int64
+	err  error
+}
+
+func (r mockedResult) LastInsertId() (int64, error) {
+	return 0, nil
+}
+
+func (r mockedResult) RowsAffected() (int64, error) {
+	return r.rows, r.err
+}
+
+func TestRowsAffected(t *testing.T) {
+	assert.Equal(t, int64(3), rowsAffected(mockedResult{rows: 3}, nil))
+	assert.Equal(t, int64(-1), rowsAffected(mockedResult{err: errors.New("any")}, nil))
+	assert.Equal(t, int64(-1), rowsAffected(nil, errors.New("any")))
+}
@@ -1,12 +1,32 @@
 package sqlx
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"github.com/z-sdk/goa/lib/logx"
+	"strconv"
+	"sync/atomic"
+	"time"
 )
 
+// 嵌套事务的保存点名称前缀
+const savepointPrefix = "sqlx_sp_"
+
 type (
-	beginTxFn func(*sql.DB) (TxSession, error)
+	beginTxFn func(context.Context, *conn, *sql.DB, *sql.TxOptions) (TxSession, error)
+
+	// TxOptions 事务选项
+	TxOptions struct {
+		sql.TxOptions              // 隔离级别和是否只读
+		Retry         *RetryPolicy // 遇死锁或锁等待超时时重跑整个事务的策略，nil 表示不重试
+	}
+
+	// RetryPolicy 事务重试策略
+	RetryPolicy struct {
+		Times    int           // 最大重试次数
+		Interval time.Duration // 每次重试前的等待时间
+	}
 
 	TxSession interface {
 		Session
@@ -16,18 +36,20 @@ type (
 
 	txSession struct {
 		*sql.Tx
+		conn       *conn
+		savepoints *uint32 // 同一事务内已创建的保存点个数，用于生成唯一的保存点名称
 	}
 )
 
-func doTx(c *conn, beginTx beginTxFn, transact TransactFn) (err error) {
-	db, err := getConn(c.driverName, c.dataSourceName)
+func doTx(ctx context.Context, c *conn, beginTx beginTxFn, opts *sql.TxOptions, transact TransactFn) (err error) {
+	db, err := getConn(c.driverName, c.dataSourceName, c.pool)
 	if err != nil {
 		logConnError(c.dataSourceName, err)
 		return err
 	}
 
 	var tx TxSession
-	tx, err = beginTx(db)
+	tx, err = beginTx(ctx, c, db, opts)
 	if err != nil {
 		return
 	}
@@ -51,22 +73,128 @@ func doTx(c *conn, beginTx beginTxFn, transact TransactFn) (err error) {
 	return transact(tx)
 }
 
-func beginTx(db *sql.DB) (TxSession, error) {
-	if tx, err := db.Begin(); err != nil {
+func beginTx(ctx context.Context, c *conn, db *sql.DB, opts *sql.TxOptions) (TxSession, error) {
+	if tx, err := db.BeginTx(ctx, opts); err != nil {
 		return nil, err
 	} else {
-		return txSession{Tx: tx}, nil
+		return txSession{
+			Tx:         tx,
+			conn:       c,
+			savepoints: new(uint32),
+		}, nil
+	}
+}
+
+// doTxWithRetry 执行事务，出错时按重试策略重跑整个事务。
+// 仅重试 retryable 认可的错误（如死锁），上下文结束后不再重试
+func doTxWithRetry(ctx context.Context, policy *RetryPolicy, retryable func(error) bool, tx func() error) error {
+	for i := 0; ; i++ {
+		err := tx()
+		if err == nil || policy == nil || i >= policy.Times || retryable == nil || !retryable(err) {
+			return err
+		}
+
+		logx.Infof("[SQL] 事务第 %d 次重试，原因: %v", i+1, err)
+		if policy.Interval <= 0 {
+			if ctx.Err() != nil {
+				return err
+			}
+			continue
+		}
+
+		timer := time.NewTimer(policy.Interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
 	}
 }
 
 // Query 带事务查询
 func (tx txSession) Query(dest interface{}, query string, args ...interface{}) error {
-	return doQuery(tx.Tx, func(rows *sql.Rows) error {
+	return tx.QueryCtx(context.Background(), dest, query, args...)
+}
+
+// QueryCtx 带事务和上下文查询
+func (tx txSession) QueryCtx(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return doQuery(ctx, tx.conn, tx.Tx, MethodTxQuery, func(rows *sql.Rows) (int64, error) {
 		return scan(rows, dest)
 	}, query, args...)
 }
 
 // Exec 带事务执行
 func (tx txSession) Exec(query string, args ...interface{}) (sql.Result, error) {
-	return doExec(tx.Tx, query, args...)
+	return tx.ExecCtx(context.Background(), query, args...)
+}
+
+// ExecCtx 带事务和上下文执行
+func (tx txSession) ExecCtx(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return doExec(ctx, tx.conn, tx.Tx, MethodTxExec, query, args...)
+}
+
+// QueryNamed 带事务的命名参数查询
+func (tx txSession) QueryNamed(dest interface{}, query string, arg interface{}) error {
+	return tx.QueryNamedCtx(context.Background(), dest, query, arg)
+}
+
+// QueryNamedCtx 带事务和上下文的命名参数查询
+func (tx txSession) QueryNamedCtx(ctx context.Context, dest interface{}, query string, arg interface{}) error {
+	query, args, err := bindNamed(query, arg)
+	if err != nil {
+		return err
+	}
+
+	return tx.QueryCtx(ctx, dest, query, args...)
+}
+
+// ExecNamed 带事务的命名参数执行
+func (tx txSession) ExecNamed(query string, arg interface{}) (sql.Result, error) {
+	return tx.ExecNamedCtx(context.Background(), query, arg)
+}
+
+// ExecNamedCtx 带事务和上下文的命名参数执行
+func (tx txSession) ExecNamedCtx(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
+	query, args, err := bindNamed(query, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	return tx.ExecCtx(ctx, query, args...)
+}
+
+// Transact 以保存点开启嵌套事务
+func (tx txSession) Transact(fn TransactFn) error {
+	return tx.TransactCtx(context.Background(), fn)
+}
+
+// TransactCtx 以保存点开启带上下文的嵌套事务，fn 出错或崩溃时仅回滚到该保存点，不影响外层事务
+func (tx txSession) TransactCtx(ctx context.Context, fn TransactFn) (err error) {
+	savepoint := savepointPrefix + strconv.FormatUint(uint64(atomic.AddUint32(tx.savepoints, 1)), 10)
+	if _, err = tx.ExecCtx(ctx, "savepoint "+savepoint); err != nil {
+		return
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			if _, e := tx.ExecCtx(ctx, "rollback to savepoint "+savepoint); e != nil {
+				err = fmt.Errorf("嵌套事务恢复自 %v, 回滚失败: %v", p, e)
+			} else {
+				err = fmt.Errorf("嵌套事务恢复自 %v", p)
+			}
+		} else if err != nil {
+			if _, e := tx.ExecCtx(ctx, "rollback to savepoint "+savepoint); e != nil {
+				err = fmt.Errorf("嵌套事务失败: %s, 回滚失败: %s", err, e)
+			}
+		} else {
+			_, err = tx.ExecCtx(ctx, "release savepoint "+savepoint)
+		}
+	}()
+
+	return fn(tx)
+}
+
+func (tx txSession) getDialect() dialect {
+	return tx.conn.dialect
 }
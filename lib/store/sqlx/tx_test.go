@@ -2,9 +2,13 @@ package sqlx
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
 	"strconv"
 	"testing"
+	"time"
 )
 
 func TestTxSession_Exec(t *testing.T) {
@@ -42,3 +46,85 @@ func addAccountCallback(tx Session) (err error) {
 
 	return nil
 }
+
+func TestTxSession_NestedTransact(t *testing.T) {
+	const dsn = "nested_tx"
+	backend := newMockedBackend(dsn + "?parseTime=true&loc=Local")
+	c := NewConn(mockedDriverName, dsn)
+
+	errInner := errors.New("inner")
+	assert.Nil(t, c.Transact(func(tx Session) error {
+		if _, err := tx.Exec("insert into a values (1)"); err != nil {
+			return err
+		}
+
+		// 成功的嵌套事务释放保存点
+		assert.Nil(t, tx.Transact(func(tx Session) error {
+			_, err := tx.Exec("insert into b values (1)")
+			return err
+		}))
+
+		// 失败和崩溃的嵌套事务只回滚到各自的保存点
+		assert.Equal(t, errInner, tx.Transact(func(tx Session) error {
+			return errInner
+		}))
+		assert.NotNil(t, tx.Transact(func(tx Session) error {
+			panic("inner")
+		}))
+
+		return nil
+	}))
+
+	assert.Equal(t, []string{
+		"begin",
+		"insert into a values (1)",
+		"savepoint sqlx_sp_1",
+		"insert into b values (1)",
+		"release savepoint sqlx_sp_1",
+		"savepoint sqlx_sp_2",
+		"rollback to savepoint sqlx_sp_2",
+		"savepoint sqlx_sp_3",
+		"rollback to savepoint sqlx_sp_3",
+		"commit",
+	}, backend.statements())
+}
+
+func TestConn_TransactWithRetry(t *testing.T) {
+	const dsn = "tx_retry"
+	newMockedBackend(dsn + "?parseTime=true&loc=Local")
+	c := NewConn(mockedDriverName, dsn, withMySQLRetryable())
+
+	var runs int
+	err := c.TransactWithOptions(TxOptions{
+		Retry: &RetryPolicy{Times: 2},
+	}, func(tx Session) error {
+		runs++
+		return &mysql.MySQLError{Number: ErrDeadlockCode}
+	})
+	assert.Equal(t, ErrDeadlockCode, err.(*mysql.MySQLError).Number)
+	assert.Equal(t, 3, runs)
+
+	// 不可重试的错误只执行一次
+	runs = 0
+	errAny := errors.New("any")
+	assert.Equal(t, errAny, c.TransactWithOptions(TxOptions{
+		Retry: &RetryPolicy{Times: 2},
+	}, func(tx Session) error {
+		runs++
+		return errAny
+	}))
+	assert.Equal(t, 1, runs)
+
+	// 重试成功后不再重跑
+	runs = 0
+	assert.Nil(t, c.TransactWithOptions(TxOptions{
+		Retry: &RetryPolicy{Times: 5, Interval: time.Millisecond},
+	}, func(tx Session) error {
+		runs++
+		if runs < 2 {
+			return &mysql.MySQLError{Number: ErrLockWaitTimeoutCode}
+		}
+		return nil
+	}))
+	assert.Equal(t, 2, runs)
+}
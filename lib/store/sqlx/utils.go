@@ -2,15 +2,42 @@ package sqlx
 
 import (
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"github.com/z-sdk/goa/lib/logx"
 	"github.com/z-sdk/goa/lib/mapping"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
-// formatQuery 格式查询字符串和参数
-func formatQuery(query string, args ...interface{}) (string, error) {
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+	mapRowType  = reflect.TypeOf(map[string]interface{}{})
+
+	// 结构体类型到字段映射元数据的缓存
+	structInfoCache sync.Map
+)
+
+type (
+	// 结构体字段与查询列的映射元数据
+	structInfo struct {
+		fields  []fieldInfo    // 按声明顺序展开的可扫描字段
+		columns map[string]int // 列名到字段下标的映射，有字段未标记列名时为 nil，按字段顺序映射
+	}
+
+	// 可扫描字段
+	fieldInfo struct {
+		index  []int  // 字段在结构体中的下标路径
+		column string // 带有嵌套前缀的列名
+	}
+)
+
+// formatDialectQuery 按方言格式查询字符串和参数
+func formatDialectQuery(d dialect, query string, args ...interface{}) (string, error) {
 	argNum := len(args)
 	if argNum == 0 {
 		return query, nil
@@ -29,16 +56,27 @@ func formatQuery(query string, args ...interface{}) (string, error) {
 			arg := args[argIdx]
 			argIdx++
 
+			// 自定义类型如 JSON 列，取其数据库值
+			if valuer, ok := arg.(driver.Valuer); ok {
+				value, err := valuer.Value()
+				if err != nil {
+					return "", err
+				}
+				arg = value
+			}
+
 			switch at := arg.(type) {
+			case nil:
+				b.WriteString("NULL")
 			case bool:
-				if at {
-					b.WriteByte('1')
-				} else {
-					b.WriteByte('0')
-				}
+				b.WriteString(d.formatBool(at))
 			case string:
 				b.WriteByte('\'')
-				b.WriteString(escape(at))
+				b.WriteString(d.escape(at))
+				b.WriteByte('\'')
+			case []byte:
+				b.WriteByte('\'')
+				b.WriteString(d.escape(string(at)))
 				b.WriteByte('\'')
 			default:
 				// 表示其他类型如 interface{} 的字符串形式
@@ -122,55 +160,77 @@ func desensitize(dsn string) string {
 	return dsn
 }
 
-func scan(rows *sql.Rows, dest interface{}) error {
+// scan 将结果集扫描进 dest，返回读取的行数
+func scan(rows *sql.Rows, dest interface{}) (int64, error) {
+	var n int64
+
 	// 验证接收目标必须为有效非空指针
 	// TODO 为什么不直接验证 dest，而是反射的值？
 	dv := reflect.ValueOf(dest)
 	if err := mapping.ValidatePtr(&dv); err != nil {
-		return err
+		return n, err
 	}
 
 	// 将行数据扫描进目标结果
 	dte := reflect.TypeOf(dest).Elem()
 	dve := dv.Elem()
-	switch dte.Kind() {
-	case reflect.Bool,
-		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
-		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
-		reflect.Float32, reflect.Float64,
-		reflect.String:
+	switch {
+	case isScalarType(dte):
 		if dve.CanSet() {
 			if !rows.Next() {
 				if err := rows.Err(); err != nil {
-					return err
+					return n, err
 				}
-				return ErrNotFound
+				return n, ErrNotFound
 			}
-			return rows.Scan(dest)
+			return 1, rows.Scan(dest)
 		} else {
-			return ErrNotSettable
+			return n, ErrNotSettable
 		}
-	case reflect.Struct:
+	case dte.Kind() == reflect.Struct:
 		if !rows.Next() {
 			if err := rows.Err(); err != nil {
-				return err
+				return n, err
 			}
-			return ErrNotFound
+			return n, ErrNotFound
 		}
+		n = 1
 		// 获取行的列名切片
 		colNames, err := rows.Columns()
 		if err != nil {
-			return err
+			return n, err
 		}
 
 		if values, err := mapStructFieldsToSlice(dve, colNames); err != nil {
-			return err
+			return n, err
 		} else {
-			return rows.Scan(values...)
+			return n, rows.Scan(values...)
 		}
-	case reflect.Slice:
+	case dte == mapRowType:
 		if !dve.CanSet() {
-			return ErrNotSettable
+			return n, ErrNotSettable
+		}
+		if !rows.Next() {
+			if err := rows.Err(); err != nil {
+				return n, err
+			}
+			return n, ErrNotFound
+		}
+		n = 1
+
+		columns, kinds, err := getMapColumns(rows)
+		if err != nil {
+			return n, err
+		}
+		row, err := scanMapRow(rows, columns, kinds)
+		if err != nil {
+			return n, err
+		}
+		dve.Set(reflect.ValueOf(row))
+		return n, nil
+	case dte.Kind() == reflect.Slice:
+		if !dve.CanSet() {
+			return n, ErrNotSettable
 		}
 
 		ptr := dte.Elem().Kind() == reflect.Ptr
@@ -194,123 +254,330 @@ func scan(rows *sql.Rows, dest interface{}) error {
 		}
 
 		base := mapping.Deref(dte.Elem())
-		switch base.Kind() {
-		case reflect.String, reflect.Bool, reflect.Float32, reflect.Float64,
-			reflect.Int, reflect.Int8, reflect.Int32, reflect.Int64,
-			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch {
+		case dte.Elem() == mapRowType:
+			columns, kinds, err := getMapColumns(rows)
+			if err != nil {
+				return n, err
+			}
+
 			for rows.Next() {
+				n++
+				row, err := scanMapRow(rows, columns, kinds)
+				if err != nil {
+					return n, err
+				}
+				dve.Set(reflect.Append(dve, reflect.ValueOf(row)))
+			}
+		case isScalarType(base):
+			for rows.Next() {
+				n++
 				value := reflect.New(base)
 				if err := fillFn(value.Interface()); err != nil {
-					return err
+					return n, err
 				}
 			}
-		case reflect.Struct:
+		case base.Kind() == reflect.Struct:
 			// 获取行的列名切片
 			colNames, err := rows.Columns()
 			if err != nil {
-				return err
+				return n, err
 			}
 
 			for rows.Next() {
+				n++
 				value := reflect.New(base)
 				if values, err := mapStructFieldsToSlice(value, colNames); err != nil {
-					return err
+					return n, err
 				} else {
 					if err := rows.Scan(values...); err != nil {
-						return err
+						return n, err
 					} else {
 						appendFn(value)
 					}
 				}
 			}
 		default:
-			return ErrUnsupportedValueType
+			return n, ErrUnsupportedValueType
 		}
-		return nil
+		return n, nil
 	default:
-		return ErrUnsupportedValueType
+		return n, ErrUnsupportedValueType
 	}
 }
 
-// 映射目标结构体字段到查询结果列，并赋初值
-func mapStructFieldsToSlice(dve reflect.Value, columns []string) ([]interface{}, error) {
-	columnValueMap, err := getColumnValueMap(dve)
+// scanStream 逐行扫描结果集，每行扫描进与 dest 同类型的新值并回调 fn，fn 返回错误则停止读取，返回读取的行数
+func scanStream(rows *sql.Rows, dest interface{}, fn StreamFn) (int64, error) {
+	var n int64
+
+	dv := reflect.ValueOf(dest)
+	if err := mapping.ValidatePtr(&dv); err != nil {
+		return n, err
+	}
+
+	base := mapping.Deref(dv.Type())
+	scalar := isScalarType(base)
+	if !scalar && base.Kind() != reflect.Struct {
+		return n, ErrUnsupportedValueType
+	}
+
+	// 获取行的列名切片
+	colNames, err := rows.Columns()
+	if err != nil {
+		return n, err
+	}
+
+	for rows.Next() {
+		n++
+		row := reflect.New(base)
+		if !scalar {
+			values, err := mapStructFieldsToSlice(row, colNames)
+			if err != nil {
+				return n, err
+			}
+			if err = rows.Scan(values...); err != nil {
+				return n, err
+			}
+		} else if err := rows.Scan(row.Interface()); err != nil {
+			return n, err
+		}
+
+		if err := fn(row.Interface()); err != nil {
+			return n, err
+		}
+	}
+
+	return n, rows.Err()
+}
+
+// getMapColumns 获取动态查询的列名及各列 []byte 值应转换的类型
+func getMapColumns(rows *sql.Rows) ([]string, []reflect.Kind, error) {
+	columnTypes, err := rows.ColumnTypes()
 	if err != nil {
+		return nil, nil, err
+	}
+
+	columns := make([]string, len(columnTypes))
+	kinds := make([]reflect.Kind, len(columnTypes))
+	for i, columnType := range columnTypes {
+		columns[i] = columnType.Name()
+		kinds[i] = getColumnKind(columnType)
+	}
+
+	return columns, kinds, nil
+}
+
+// getColumnKind 根据驱动提供的扫描类型或数据库类型名，推断列值的类型
+func getColumnKind(columnType *sql.ColumnType) reflect.Kind {
+	if scanType := columnType.ScanType(); scanType != nil {
+		switch scanType.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return reflect.Int64
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return reflect.Uint64
+		case reflect.Float32, reflect.Float64:
+			return reflect.Float64
+		case reflect.Bool:
+			return reflect.Bool
+		}
+	}
+
+	// 可为 NULL 的列扫描类型为 sql.NullInt64 等，按数据库类型名推断
+	switch strings.ToUpper(columnType.DatabaseTypeName()) {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "INTEGER", "BIGINT", "YEAR", "INT2", "INT4", "INT8":
+		return reflect.Int64
+	case "FLOAT", "DOUBLE", "REAL", "FLOAT4", "FLOAT8":
+		return reflect.Float64
+	case "BOOL", "BOOLEAN":
+		return reflect.Bool
+	default:
+		return reflect.String
+	}
+}
+
+// scanMapRow 扫描当前行为 列名->值 映射，[]byte 值按列类型转换，无法转换时转为字符串
+func scanMapRow(rows *sql.Rows, columns []string, kinds []reflect.Kind) (map[string]interface{}, error) {
+	values := make([]interface{}, len(columns))
+	for i := range values {
+		values[i] = new(interface{})
+	}
+	if err := rows.Scan(values...); err != nil {
 		return nil, err
 	}
 
+	row := make(map[string]interface{}, len(columns))
+	for i, column := range columns {
+		value := *values[i].(*interface{})
+		if bs, ok := value.([]byte); ok {
+			value = convertBytes(string(bs), kinds[i])
+		}
+		row[column] = value
+	}
+
+	return row, nil
+}
+
+// convertBytes 将字符串形式的列值转换为指定类型
+func convertBytes(str string, kind reflect.Kind) interface{} {
+	switch kind {
+	case reflect.Int64:
+		if v, err := strconv.ParseInt(str, 10, 64); err == nil {
+			return v
+		}
+	case reflect.Uint64:
+		if v, err := strconv.ParseUint(str, 10, 64); err == nil {
+			return v
+		}
+	case reflect.Float64:
+		if v, err := strconv.ParseFloat(str, 64); err == nil {
+			return v
+		}
+	case reflect.Bool:
+		if v, err := strconv.ParseBool(str); err == nil {
+			return v
+		}
+	}
+
+	return str
+}
+
+// 映射目标结构体字段到查询结果列，返回各列的扫描目标
+// 字段均标记列名时按列名映射，否则按字段顺序映射，没有对应字段的列被忽略
+func mapStructFieldsToSlice(dve reflect.Value, columns []string) ([]interface{}, error) {
+	v := reflect.Indirect(dve)
+	info := getStructInfo(v.Type())
+
 	values := make([]interface{}, len(columns))
-	if len(columnValueMap) != 0 {
-		for i, column := range columns {
-			if value, ok := columnValueMap[column]; ok {
-				values[i] = value
-			} else {
-				var anonymous interface{}
-				values[i] = &anonymous
+	for i, column := range columns {
+		var fieldIndex []int
+		if info.columns != nil {
+			if idx, ok := info.columns[column]; ok {
+				fieldIndex = info.fields[idx].index
 			}
+		} else if i < len(info.fields) {
+			fieldIndex = info.fields[i].index
 		}
-	} else {
-		fields := getFields(dve)
-
-		for i := 0; i < len(values); i++ {
-			field := fields[i]
-			switch field.Kind() {
-			case reflect.Ptr:
-				if !field.CanInterface() {
-					return nil, ErrNotReadableValue
-				}
-				if field.IsNil() {
-					baseValueType := mapping.Deref(field.Type())
-					field.Set(reflect.New(baseValueType))
-				}
-				values[i] = field.Interface()
-			default:
-				if !field.CanAddr() || !field.Addr().CanInterface() {
-					return nil, ErrNotReadableValue
-				}
-				values[i] = field.Addr().Interface()
-			}
+
+		if fieldIndex == nil {
+			var anonymous interface{}
+			values[i] = &anonymous
+			continue
+		}
+
+		// 指针字段传入指针的地址，NULL 时置为 nil
+		field := fieldByIndex(v, fieldIndex)
+		if !field.CanAddr() || !field.Addr().CanInterface() {
+			return nil, ErrNotReadableValue
 		}
+		values[i] = field.Addr().Interface()
 	}
 
 	return values, nil
 }
 
-// getColumnValueMap: 获取结构体字段中标记的列名——值映射关系
-// 在编写字段tag的情况下，可以确保结构体字段和SQL选择列不一致的情况下不出错
-func getColumnValueMap(dve reflect.Value) (map[string]interface{}, error) {
-	t := mapping.Deref(dve.Type())
-	size := t.NumField()
-	result := make(map[string]interface{}, size)
+// getStructInfo 获取结构体类型的字段映射元数据，按类型缓存
+func getStructInfo(t reflect.Type) *structInfo {
+	if info, ok := structInfoCache.Load(t); ok {
+		return info.(*structInfo)
+	}
 
-	for i := 0; i < size; i++ {
-		// 取字段标记中的列名，如`conn:"total"` 中的 total
-		columnName := getColumnName(t.Field(i))
-		if len(columnName) == 0 {
-			return nil, nil
+	info := new(structInfo)
+	tagged := collectFields(t, nil, "", info)
+	if tagged {
+		info.columns = make(map[string]int, len(info.fields))
+		for i, field := range info.fields {
+			if _, ok := info.columns[field.column]; !ok {
+				info.columns[field.column] = i
+			}
 		}
+	}
+
+	actual, _ := structInfoCache.LoadOrStore(t, info)
+	return actual.(*structInfo)
+}
 
-		// 读取指针字段或非指针字段的值
-		field := reflect.Indirect(dve).Field(i)
-		switch field.Kind() {
-		case reflect.Ptr:
-			if !field.CanInterface() {
-				return nil, ErrNotReadableValue
+// collectFields 递归收集结构体的可扫描字段，返回是否所有字段都标记了列名
+// 匿名嵌套结构体展开到当前层级，标记了列名的嵌套结构体以 "列名." 作为其字段的列名前缀
+func collectFields(t reflect.Type, index []int, prefix string, info *structInfo) bool {
+	tagged := true
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		columnName := getColumnName(field)
+		if columnName == "-" {
+			continue
+		}
+
+		fieldIndex := make([]int, len(index)+1)
+		copy(fieldIndex, index)
+		fieldIndex[len(index)] = i
+
+		// 嵌套结构体
+		typ := mapping.Deref(field.Type)
+		if typ.Kind() == reflect.Struct && !isScalarType(typ) && (field.Anonymous || len(columnName) > 0) {
+			// 未导出的嵌套结构体指针无法分配
+			if field.PkgPath != "" && field.Type.Kind() == reflect.Ptr {
+				continue
 			}
-			if field.IsNil() {
-				typ := mapping.Deref(field.Type())
-				field.Set(reflect.New(typ))
+			nestedPrefix := prefix
+			if len(columnName) > 0 {
+				nestedPrefix = prefix + columnName + "."
 			}
-			result[columnName] = field.Interface()
-		default:
-			if !field.CanAddr() || !field.Addr().CanInterface() {
-				return nil, ErrNotReadableValue
+			if !collectFields(typ, fieldIndex, nestedPrefix, info) {
+				tagged = false
+			}
+			continue
+		}
+
+		// 未导出字段
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if len(columnName) == 0 {
+			tagged = false
+		} else {
+			columnName = prefix + columnName
+		}
+		info.fields = append(info.fields, fieldInfo{
+			index:  fieldIndex,
+			column: columnName,
+		})
+	}
+
+	return tagged
+}
+
+// fieldByIndex 按下标路径取嵌套字段，途经的空指针嵌套结构体会被分配
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
 			}
-			result[columnName] = field.Addr().Interface()
+			v = v.Elem()
 		}
+		v = v.Field(x)
+	}
+
+	return v
+}
+
+// isScalarType 是否作为单个值扫描的类型：基础类型、time.Time 或实现了 sql.Scanner 的类型
+func isScalarType(t reflect.Type) bool {
+	if t == timeType || reflect.PtrTo(t).Implements(scannerType) {
+		return true
 	}
 
-	return result, nil
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64,
+		reflect.String:
+		return true
+	default:
+		return false
+	}
 }
 
 // getColumnName 解析结构体字段中的数据库字段标记
@@ -322,31 +589,3 @@ func getColumnName(field reflect.StructField) string {
 		return strings.Split(tagName, ",")[0]
 	}
 }
-
-// getFields 递归获取目标结构体的字段列表
-func getFields(dve reflect.Value) []reflect.Value {
-	var fields []reflect.Value
-	v := reflect.Indirect(dve) // 指针取值
-
-	// 递归目标字段
-	for i := 0; i < v.NumField(); i++ {
-		field := v.Field(i)
-		// 指针取值
-		if field.Kind() == reflect.Ptr && field.IsNil() {
-			baseValueType := mapping.Deref(field.Type()) // 解引用，取值
-			field.Set(reflect.New(baseValueType))
-		}
-
-		field = reflect.Indirect(field)
-		structField := v.Type().Field(i)
-
-		// 嵌套字段
-		if field.Kind() == reflect.Struct && structField.Anonymous {
-			fields = append(fields, getFields(field)...)
-		} else {
-			fields = append(fields, field)
-		}
-	}
-
-	return fields
-}
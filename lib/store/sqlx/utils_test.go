@@ -0,0 +1,172 @@
+package sqlx
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type jsonTags []string
+
+func (j *jsonTags) Scan(src interface{}) error {
+	return json.Unmarshal(src.([]byte), j)
+}
+
+func (j jsonTags) Value() (driver.Value, error) {
+	return json.Marshal(j)
+}
+
+func TestScan_Struct(t *testing.T) {
+	type (
+		Base struct {
+			Id int64 `db:"id"`
+		}
+		Audit struct {
+			CreatedBy string `db:"created_by"`
+		}
+		Author struct {
+			Name string `db:"name"`
+		}
+		Post struct {
+			Base
+			*Audit
+			Author  Author         `db:"author"`
+			Title   *string        `db:"title"`
+			Summary sql.NullString `db:"summary"`
+			Tags    jsonTags       `db:"tags"`
+			Ignored string         `db:"-"`
+			hidden  string
+		}
+	)
+
+	const dsn = "scan_struct"
+	backend := newMockedBackend(dsn + "?parseTime=true&loc=Local")
+	backend.setRows([]string{"id", "created_by", "author.name", "title", "summary", "tags", "other"},
+		[]driver.Value{int64(1), "admin", "张三", nil, "摘要", []byte(`["go","sql"]`), "x"},
+		[]driver.Value{int64(2), "admin", "李四", "标题", nil, []byte(`[]`), "y"})
+	c := NewConn(mockedDriverName, dsn)
+
+	var posts []Post
+	assert.Nil(t, c.Query(&posts, "select * from post"))
+	assert.Equal(t, 2, len(posts))
+	assert.Equal(t, int64(1), posts[0].Id)
+	assert.Equal(t, "admin", posts[0].CreatedBy)
+	assert.Equal(t, "张三", posts[0].Author.Name)
+	assert.Nil(t, posts[0].Title)
+	assert.Equal(t, sql.NullString{String: "摘要", Valid: true}, posts[0].Summary)
+	assert.Equal(t, jsonTags{"go", "sql"}, posts[0].Tags)
+	assert.Equal(t, "标题", *posts[1].Title)
+	assert.False(t, posts[1].Summary.Valid)
+
+	// 字段元数据按类型缓存
+	info := getStructInfo(reflect.TypeOf(Post{}))
+	assert.True(t, info == getStructInfo(reflect.TypeOf(Post{})))
+	assert.Equal(t, map[string]int{"id": 0, "created_by": 1, "author.name": 2, "title": 3, "summary": 4,
+		"tags": 5}, info.columns)
+
+	// 实现 sql.Scanner 的类型作为单个值扫描
+	backend.setRows([]string{"summary"}, []driver.Value{"摘要"})
+	var summary sql.NullString
+	assert.Nil(t, c.Query(&summary, "select summary from post limit 1"))
+	assert.Equal(t, "摘要", summary.String)
+}
+
+func TestScan_UntaggedStruct(t *testing.T) {
+	type user struct {
+		Id   int64
+		Name string
+	}
+
+	const dsn = "scan_untagged"
+	backend := newMockedBackend(dsn + "?parseTime=true&loc=Local")
+	backend.setRows([]string{"id", "name"}, []driver.Value{int64(1), "张三"})
+	c := NewConn(mockedDriverName, dsn)
+
+	// 未标记列名时按字段顺序映射
+	var u user
+	assert.Nil(t, c.Query(&u, "select id, name from user limit 1"))
+	assert.Equal(t, user{Id: 1, Name: "张三"}, u)
+}
+
+func TestFormatDialectQuery_Valuer(t *testing.T) {
+	stmt, err := formatDialectQuery(mysqlDialect{}, "insert into post(tags, title) values (?, ?)",
+		jsonTags{"go"}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, `insert into post(tags, title) values ('[\"go\"]', NULL)`, stmt)
+}
+
+func TestScan_Map(t *testing.T) {
+	const dsn = "scan_map"
+	backend := newMockedBackend(dsn + "?parseTime=true&loc=Local")
+	backend.setRows([]string{"id", "name", "score", "remark"},
+		[]driver.Value{[]byte("1"), []byte("张三"), []byte("9.5"), nil},
+		[]driver.Value{[]byte("2"), []byte("李四"), []byte("8"), []byte("备注")})
+	backend.setColumnTypes("BIGINT", "VARCHAR", "DOUBLE", "TEXT")
+	c := NewConn(mockedDriverName, dsn)
+
+	var row map[string]interface{}
+	assert.Nil(t, c.Query(&row, "select id, name, score, remark from user limit 1"))
+	assert.Equal(t, map[string]interface{}{"id": int64(1), "name": "张三", "score": 9.5, "remark": nil}, row)
+
+	var result []map[string]interface{}
+	assert.Nil(t, c.Query(&result, "select id, name, score, remark from user"))
+	assert.Equal(t, []map[string]interface{}{
+		{"id": int64(1), "name": "张三", "score": 9.5, "remark": nil},
+		{"id": int64(2), "name": "李四", "score": float64(8), "remark": "备注"},
+	}, result)
+
+	backend.setRows([]string{"id"})
+	assert.Equal(t, ErrNotFound, c.Query(&row, "select id from user limit 1"))
+}
+
+func TestScan_Rows(t *testing.T) {
+	const dsn = "scan_rows?parseTime=true&loc=Local"
+	backend := newMockedBackend(dsn)
+	backend.setRows([]string{"id"}, []driver.Value{int64(1)}, []driver.Value{int64(2)}, []driver.Value{int64(3)})
+	db, err := sql.Open(mockedDriverName, dsn)
+	assert.Nil(t, err)
+	defer db.Close()
+
+	scanRows := func(fn func(rows *sql.Rows) (int64, error)) (int64, error) {
+		rows, err := db.Query("select id from user")
+		assert.Nil(t, err)
+		defer rows.Close()
+		return fn(rows)
+	}
+
+	var ids []int64
+	n, err := scanRows(func(rows *sql.Rows) (int64, error) {
+		return scan(rows, &ids)
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), n)
+
+	var id int64
+	n, err = scanRows(func(rows *sql.Rows) (int64, error) {
+		return scan(rows, &id)
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+
+	n, err = scanRows(func(rows *sql.Rows) (int64, error) {
+		return scanStream(rows, &id, func(row interface{}) error {
+			if *row.(*int64) == 2 {
+				return ErrNotFound
+			}
+			return nil
+		})
+	})
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, int64(2), n)
+
+	backend.setRows([]string{"id"})
+	n, err = scanRows(func(rows *sql.Rows) (int64, error) {
+		return scan(rows, &id)
+	})
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, int64(0), n)
+}
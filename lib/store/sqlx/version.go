@@ -0,0 +1,33 @@
+package sqlx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+var ErrVersionConflict = errors.New("数据版本冲突，已被其他请求修改")
+
+// ExecWithVersion 执行乐观锁更新，形如 update ... set ..., version = version + 1 where id = ? and version = ?
+// 未更新任何行时返回 ErrVersionConflict。语句须同时递增版本号，以免 MySQL 因值未变而不计入影响行数
+func ExecWithVersion(s Session, query string, args ...interface{}) (sql.Result, error) {
+	return ExecWithVersionCtx(context.Background(), s, query, args...)
+}
+
+// ExecWithVersionCtx 带上下文执行乐观锁更新
+func ExecWithVersionCtx(ctx context.Context, s Session, query string, args ...interface{}) (sql.Result, error) {
+	result, err := s.ExecCtx(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		return nil, ErrVersionConflict
+	}
+
+	return result, nil
+}
@@ -0,0 +1,28 @@
+package sqlx
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecWithVersion(t *testing.T) {
+	const query = "update tag set name = ?, version = version + 1 where id = ? and version = ?"
+	c := NewConn(mockedDriverName, "exec_with_version")
+	_, err := ExecWithVersion(c, query, "张三", 1, 3)
+	assert.Nil(t, err)
+
+	_, err = ExecWithVersion(conflictSession{c}, query, "张三", 1, 2)
+	assert.Equal(t, ErrVersionConflict, err)
+}
+
+// conflictSession 执行语句均未影响任何行
+type conflictSession struct {
+	Session
+}
+
+func (s conflictSession) ExecCtx(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return mockedResult{}, nil
+}
@@ -60,3 +60,17 @@ func (m *ResourceManager) Get(key string, getFn func() (io.Closer, error)) (io.C
 	}
 	return result.(io.Closer), nil
 }
+
+// Remove 关闭并移除指定 key 的缓存资源，资源不存在时不做任何事，之后再获取时会重新回源
+func (m *ResourceManager) Remove(key string) error {
+	m.lock.Lock()
+	res, ok := m.resources[key]
+	delete(m.resources, key)
+	m.lock.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	return res.Close()
+}
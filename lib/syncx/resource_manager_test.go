@@ -0,0 +1,41 @@
+package syncx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+type dummyResource struct {
+	closed int
+}
+
+func (r *dummyResource) Close() error {
+	r.closed++
+	return nil
+}
+
+func TestResourceManager_Remove(t *testing.T) {
+	m := NewResourceManager()
+	res := new(dummyResource)
+	var created int
+	get := func() (io.Closer, error) {
+		created++
+		return res, nil
+	}
+
+	_, err := m.Get("key", get)
+	assert.Nil(t, err)
+	_, err = m.Get("key", get)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, created)
+
+	// 移除时关闭资源，之后再获取时重新回源
+	assert.Nil(t, m.Remove("key"))
+	assert.Equal(t, 1, res.closed)
+	assert.Nil(t, m.Remove("key"))
+	assert.Equal(t, 1, res.closed)
+	_, err = m.Get("key", get)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, created)
+}
@@ -31,6 +31,10 @@ var (
 					Name:  "cache, c",
 					Usage: "生成带缓存的数据访问层[可选]",
 				},
+				cli.StringFlag{
+					Name:  "soft-delete, s",
+					Usage: `启用软删除并指定软删除列，多列以英文逗号分隔，取表中首个存在的列，如 "deleted_at,is_valid=0"，未指定时生成物理删除[可选]`,
+				},
 			},
 		},
 	}
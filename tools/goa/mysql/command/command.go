@@ -16,6 +16,8 @@ const (
 	flagTable = "table"
 	flagDir   = "dir"
 	flagCache = "cache"
+
+	flagSoftDelete = "soft-delete"
 )
 
 func GenCodeFromDSN(ctx *cli.Context) error {
@@ -23,6 +25,7 @@ func GenCodeFromDSN(ctx *cli.Context) error {
 	dir := ctx.String(flagDir)
 	cache := ctx.Bool(flagCache)
 	table := strings.TrimSpace(ctx.String(flagTable))
+	softDelete := strings.TrimSpace(ctx.String(flagSoftDelete))
 
 	if len(dsn) == 0 {
 		logx.Error("MySQL连接地址未提供")
@@ -52,7 +55,11 @@ func GenCodeFromDSN(ctx *cli.Context) error {
 
 	//fmt.Println(strings.Join(ddlList, "\n"), dir, cache)
 	log := util.NewConsole(true)
-	generator := gen.NewModelGenerator(ddlList, dir, gen.WithConsoleOption(log))
+	opts := []gen.Option{gen.WithConsoleOption(log)}
+	if len(softDelete) > 0 {
+		opts = append(opts, gen.WithSoftDelete(strings.Split(softDelete, ",")...))
+	}
+	generator := gen.NewModelGenerator(ddlList, dir, opts...)
 	err = generator.Start(cache)
 	if err != nil {
 		log.Error("", err)
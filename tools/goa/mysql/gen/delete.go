@@ -26,6 +26,13 @@ func genDelete(table Table, withCache bool) (string, error) {
 			break
 		}
 	}
+	var deleteAssignment, deleteArgs string
+	if table.SoftDelete != nil {
+		deleteAssignment = table.SoftDelete.DeleteAssignment()
+		if table.SoftDelete.IsTime() {
+			deleteArgs = "time.Now(), "
+		}
+	}
 	upperTable := table.Name.ToCamel()
 	output, err := util.With("delete").Parse(tpl.Delete).Execute(map[string]interface{}{
 		"upperStartCamelObject":     upperTable,
@@ -36,6 +43,9 @@ func genDelete(table Table, withCache bool) (string, error) {
 		"keys":                      strings.Join(keySet.KeysStr(), "\n"),
 		"originalPrimaryKey":        table.PrimaryKey.Name.Source(),
 		"keyValues":                 strings.Join(keyNamesSet.KeysStr(), ", "),
+		"softDelete":                table.SoftDelete != nil,
+		"deleteAssignment":          deleteAssignment,
+		"deleteArgs":                deleteArgs,
 	})
 	if err != nil {
 		return "", err
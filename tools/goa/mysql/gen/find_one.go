@@ -17,9 +17,33 @@ func genFindOne(table Table, withCache bool) (string, error) {
 		"dataType":           table.PrimaryKey.DataType,
 		"cacheKeyName":       table.CacheKeys[table.PrimaryKey.Name.Source()].KeyName,
 		"cacheKeyExpression": table.CacheKeys[table.PrimaryKey.Name.Source()].KeyExpression,
+		"softDelete":         table.SoftDelete != nil,
+		"alive":              aliveCondition(table),
 	})
 	if err != nil {
 		return "", err
 	}
-	return output.String(), nil
+	if table.SoftDelete == nil {
+		return output.String(), nil
+	}
+
+	// 软删除表生成包含已删除行的查询
+	trashed, err := util.With("findWithTrashed").Parse(tpl.FindWithTrashed).Execute(map[string]interface{}{
+		"withCache":  withCache,
+		"upperTable": upperTable,
+		"lowerTable": stringx.From(upperTable).UnTitle(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return output.String() + trashed.String(), nil
+}
+
+// aliveCondition 查询未删除行时追加的条件
+func aliveCondition(table Table) string {
+	if table.SoftDelete == nil {
+		return ""
+	}
+
+	return " and " + table.SoftDelete.AliveCondition()
 }
@@ -30,6 +30,8 @@ func genFindOneByField(table Table, withCache bool) (string, error) {
 			"upperStartCamelPrimaryKey": table.PrimaryKey.Name.ToCamel(),
 			"originalField":             field.Name.Source(),
 			"originalPrimaryField":      table.PrimaryKey.Name.Source(),
+			"softDelete":                table.SoftDelete != nil,
+			"alive":                     aliveCondition(table),
 		})
 		if err != nil {
 			return "", err
@@ -19,8 +19,9 @@ const (
 
 type (
 	ModelGenerator struct {
-		ddlList []string
-		dir     string
+		ddlList     []string
+		dir         string
+		softDeletes []string
 		util.Console
 	}
 
@@ -28,7 +29,8 @@ type (
 
 	Table struct {
 		parser.Table
-		CacheKeys map[string]Key
+		CacheKeys  map[string]Key
+		SoftDelete *SoftDelete
 	}
 )
 
@@ -58,6 +60,17 @@ func WithConsoleOption(c util.Console) Option {
 	}
 }
 
+// WithSoftDelete 启用软删除并指定软删除列，如 deleted_at、is_valid=0，按顺序取表中首个存在的列，
+// 未指定时识别 deleted_at 和 is_deleted=1。默认不启用，Delete 生成物理删除
+func WithSoftDelete(specs ...string) Option {
+	return func(gen *ModelGenerator) {
+		if len(specs) == 0 {
+			specs = defaultSoftDeletes
+		}
+		gen.softDeletes = specs
+	}
+}
+
 func (g *ModelGenerator) Start(withCache bool) error {
 	dir, err := filepath.Abs(g.dir)
 	if err != nil {
@@ -124,50 +137,69 @@ func (g *ModelGenerator) genModelCode(table parser.Table, withCache bool) (strin
 		return "", err
 	}
 
+	// 识别软删除列
+	softDelete, err := parseSoftDelete(table, g.softDeletes)
+	if err != nil {
+		return "", err
+	}
+
 	var tableDTO Table
 	tableDTO.Table = table
 	tableDTO.CacheKeys = cacheKeys
+	tableDTO.SoftDelete = softDelete
 
 	// 生成导包代码
 	importsCode, err := genImports(withCache, tableDTO.ContainsTime())
 	if err != nil {
-		return "", nil
+		return "", err
+	}
+
+	// 未删除时软删除时间列为 NULL，生成指针类型
+	if softDelete != nil && softDelete.IsTime() {
+		fields := make([]parser.Field, len(table.Fields))
+		copy(fields, table.Fields)
+		for i := range fields {
+			if fields[i].Name.Source() == softDelete.Field.Name.Source() {
+				fields[i].DataType = "*" + timeType
+			}
+		}
+		tableDTO.Fields = fields
 	}
 
 	// 生成变量声明代码段
 	varsCode, err := genVars(tableDTO, withCache)
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 
 	// 生成类型声明代码段
 	typesCode, err := genTypes(tableDTO, withCache)
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 
 	// 生成新生成模型的代码段
 	newCode, err := genNew(tableDTO, withCache)
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 
 	// 生成数据插入代码段
 	insertCode, err := genInsert(tableDTO, withCache)
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 
 	// 生成主键查找代码段
 	findOneCode, err := genFindOne(tableDTO, withCache)
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 
 	// 生成字段查找代码段
 	findOneByFieldCode, err := genFindOneByField(tableDTO, withCache)
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 
 	// 合成查找代码段
@@ -177,13 +209,13 @@ func (g *ModelGenerator) genModelCode(table parser.Table, withCache bool) (strin
 	// 生成更新代码段
 	updateCode, err := genUpdate(tableDTO, withCache)
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 
 	// 合成删除代码段
 	deleteCode, err := genDelete(tableDTO, withCache)
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 
 	// 合成并输出模板字符串
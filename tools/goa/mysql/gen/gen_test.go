@@ -0,0 +1,194 @@
+package gen
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/z-sdk/goa/tools/goa/mysql/parser"
+	"github.com/z-sdk/goa/tools/goa/mysql/tpl"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	userDDL = "CREATE TABLE `user` (\n" +
+		"  `id` bigint NOT NULL AUTO_INCREMENT,\n" +
+		"  `name` varchar(255) NOT NULL DEFAULT '' COMMENT '用户名',\n" +
+		"  `mobile` varchar(32) NOT NULL DEFAULT '',\n" +
+		"  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,\n" +
+		"  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  UNIQUE KEY `mobile_unique` (`mobile`)\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"
+
+	softDeleteDDL = "CREATE TABLE `post` (\n" +
+		"  `id` bigint NOT NULL AUTO_INCREMENT,\n" +
+		"  `title` varchar(255) NOT NULL DEFAULT '',\n" +
+		"  `deleted_at` timestamp NULL DEFAULT NULL,\n" +
+		"  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,\n" +
+		"  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,\n" +
+		"  PRIMARY KEY (`id`)\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"
+
+	flagDeleteDDL = "CREATE TABLE `tag` (\n" +
+		"  `id` bigint NOT NULL AUTO_INCREMENT,\n" +
+		"  `name` varchar(64) NOT NULL DEFAULT '',\n" +
+		"  `is_deleted` tinyint NOT NULL DEFAULT '0',\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  UNIQUE KEY `name_unique` (`name`)\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"
+
+	versionDDL = "CREATE TABLE `article` (\n" +
+		"  `id` bigint NOT NULL AUTO_INCREMENT,\n" +
+		"  `title` varchar(255) NOT NULL DEFAULT '',\n" +
+		"  `version` bigint NOT NULL DEFAULT '0',\n" +
+		"  PRIMARY KEY (`id`)\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"
+
+	// versionModelTest 以伪造的连接执行生成的 Update，校验影响行数为 0 时返回版本冲突
+	versionModelTest = `package model
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/z-sdk/goa/lib/store/sqlx"
+)
+
+type fakeConn struct {
+	sqlx.Conn
+	affected int64
+	query    string
+	args     []interface{}
+}
+
+func (c *fakeConn) ExecCtx(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	c.query, c.args = query, args
+	return fakeResult(c.affected), nil
+}
+
+type fakeResult int64
+
+func (r fakeResult) LastInsertId() (int64, error) { return 0, nil }
+func (r fakeResult) RowsAffected() (int64, error) { return int64(r), nil }
+
+func TestUpdate(t *testing.T) {
+	conn := &fakeConn{}
+	m := NewArticleModel(conn, "article")
+	if err := m.Update(Article{Id: 1, Title: "goa", Version: 3}); err != ErrVersionConflict {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+	if conn.query != "update article set title=?, version = version + 1 where id = ? and version = ?" {
+		t.Fatalf("unexpected query: %s", conn.query)
+	}
+	if len(conn.args) != 3 || conn.args[0] != "goa" || conn.args[1] != int64(1) || conn.args[2] != int64(3) {
+		t.Fatalf("unexpected args: %v", conn.args)
+	}
+
+	conn.affected = 1
+	if err := m.Update(Article{Id: 1, Title: "goa", Version: 3}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+`
+)
+
+func TestGenModelCode_SoftDelete(t *testing.T) {
+	for _, withCache := range []bool{true, false} {
+		// 默认不启用软删除，即使表中有 deleted_at 列
+		code := genModel(t, softDeleteDDL, withCache)
+		assert.Contains(t, code, "`delete from ` + m.table + ` where id = ?`")
+		assert.NotContains(t, code, "deleted_at is null")
+		assert.NotContains(t, code, "WithTrashed")
+		assert.Contains(t, code, "DeletedAt time.Time")
+		assertCompiles(t, code)
+
+		// 启用后 Delete 只标记删除，查询过滤已删除的行
+		code = genModel(t, softDeleteDDL, withCache, WithSoftDelete())
+		assert.Contains(t, code, "`update ` + m.table + ` set deleted_at = ? where id = ?`")
+		assert.Contains(t, code, "time.Now(), id)")
+		assert.Contains(t, code, "where id = ? and deleted_at is null limit 1")
+		assert.Contains(t, code, "func (m *PostModel) WithTrashed() *PostModel")
+		assert.Contains(t, code, "return m.findWithTrashed(\"id\", id)")
+		assert.Contains(t, code, "DeletedAt *time.Time")
+		assert.NotContains(t, code, "delete from")
+		assertCompiles(t, code)
+
+		// 整型标记列，唯一索引查询同样过滤
+		code = genModel(t, flagDeleteDDL, withCache, WithSoftDelete())
+		assert.Contains(t, code, "`update ` + m.table + ` set is_deleted = 1 where id = ?`")
+		assert.Contains(t, code, "where name = ? and is_deleted <> 1 limit 1")
+		assertCompiles(t, code)
+
+		// 表中没有软删除列时与未启用一致
+		code = genModel(t, userDDL, withCache, WithSoftDelete())
+		assert.Contains(t, code, "`delete from ` + m.table + ` where id = ?`")
+		assert.Contains(t, code, "where mobile = ? limit 1")
+		assert.NotContains(t, code, "WithTrashed")
+		assertCompiles(t, code)
+	}
+
+	// 指定的软删除列类型不支持
+	table, err := parser.Parse(userDDL)
+	assert.Nil(t, err)
+	_, err = NewModelGenerator(nil, "", WithSoftDelete("name")).genModelCode(*table, false)
+	assert.NotNil(t, err)
+}
+
+func TestGenModelCode_Version(t *testing.T) {
+	for _, withCache := range []bool{true, false} {
+		code := genModel(t, versionDDL, withCache)
+		assert.Contains(t, code, "articleFieldsWithPlaceHolder = strings.Join(stringx.Remove(articleFieldNames, \"id\", \"created_at\", \"updated_at\", \"version\")")
+		assert.Contains(t, code, ", version = version + 1 where id = ? and version = ?`")
+		assert.Contains(t, code, "sqlx.ExecWithVersion(")
+		assert.Contains(t, code, "data.Title, data.Id, data.Version)")
+		if withCache {
+			assertCompiles(t, code)
+		} else {
+			assertCompiles(t, code, map[string]string{"model_test.go": versionModelTest})
+		}
+	}
+
+	// 没有 version 列时不生成乐观锁
+	code := genModel(t, userDDL, false)
+	assert.NotContains(t, code, "version")
+	assert.NotContains(t, code, "ExecWithVersion")
+}
+
+// genModel 由 ddl 生成模型代码
+func genModel(t *testing.T, ddl string, withCache bool, opts ...Option) string {
+	table, err := parser.Parse(ddl)
+	assert.Nil(t, err)
+
+	code, err := NewModelGenerator([]string{ddl}, "", opts...).genModelCode(*table, withCache)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, code)
+	return code
+}
+
+// assertCompiles 在模块内的临时包中编译生成的模型代码，附带测试文件时运行其测试
+func assertCompiles(t *testing.T, code string, files ...map[string]string) {
+	dir, err := ioutil.TempDir(".", "_model")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "model.go"), []byte(code), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "vars.go"), []byte(tpl.Error), 0644))
+
+	args := []string{"build"}
+	for _, item := range files {
+		for name, content := range item {
+			assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+			if strings.HasSuffix(name, "_test.go") {
+				args = []string{"test", "-count=1"}
+			}
+		}
+	}
+
+	cmd := exec.Command("go", append(args, "./"+filepath.Base(dir))...)
+	output, err := cmd.CombinedOutput()
+	assert.Nil(t, err, string(output))
+}
@@ -7,8 +7,9 @@ import (
 
 func genNew(table Table, withCache bool) (string, error) {
 	output, err := util.With("new").Parse(tpl.New).Execute(map[string]interface{}{
-		"withCache": withCache,
-		"table":     table.Name.ToCamel(),
+		"withCache":  withCache,
+		"table":      table.Name.ToCamel(),
+		"softDelete": table.SoftDelete != nil,
 	})
 	if err != nil {
 		return "", err
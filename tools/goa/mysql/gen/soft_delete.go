@@ -0,0 +1,79 @@
+package gen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/z-sdk/goa/tools/goa/mysql/parser"
+)
+
+const timeType = "time.Time"
+
+// 启用软删除但未指定软删除列时识别的列及其已删除值
+var defaultSoftDeletes = []string{"deleted_at", "is_deleted=1"}
+
+// SoftDelete 软删除列：时间列以非 NULL 表示已删除，整型列以指定值表示已删除
+type SoftDelete struct {
+	Field        parser.Field
+	DeletedValue string
+}
+
+// parseSoftDelete 按 列名[=已删除值] 在表中查找软删除列，如 deleted_at、is_valid=0
+func parseSoftDelete(table parser.Table, specs []string) (*SoftDelete, error) {
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if len(spec) == 0 {
+			continue
+		}
+
+		column := spec
+		var value string
+		if pos := strings.IndexByte(spec, '='); pos >= 0 {
+			column = strings.TrimSpace(spec[:pos])
+			value = strings.TrimSpace(spec[pos+1:])
+		}
+
+		for _, field := range table.Fields {
+			if field.Name.Source() != column || field.IsPrimaryKey {
+				continue
+			}
+
+			switch field.DataType {
+			case timeType:
+				return &SoftDelete{Field: field}, nil
+			case "int64":
+				if len(value) == 0 {
+					value = "1"
+				}
+				return &SoftDelete{Field: field, DeletedValue: value}, nil
+			default:
+				return nil, fmt.Errorf("软删除列 %s 须为时间或整型", column)
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// IsTime 是否以时间列标记删除
+func (s *SoftDelete) IsTime() bool {
+	return s.Field.DataType == timeType
+}
+
+// AliveCondition 未删除行的查询条件，如 deleted_at is null
+func (s *SoftDelete) AliveCondition() string {
+	if s.IsTime() {
+		return fmt.Sprintf("%s is null", s.Field.Name.Source())
+	}
+
+	return fmt.Sprintf("%s <> %s", s.Field.Name.Source(), s.DeletedValue)
+}
+
+// DeleteAssignment 软删除时的赋值语句，时间列以 ? 占位，值为 time.Now()
+func (s *SoftDelete) DeleteAssignment() string {
+	if s.IsTime() {
+		return fmt.Sprintf("%s = ?", s.Field.Name.Source())
+	}
+
+	return fmt.Sprintf("%s = %s", s.Field.Name.Source(), s.DeletedValue)
+}
@@ -14,9 +14,10 @@ func genTypes(table Table, withCache bool) (string, error) {
 	output, err := util.With("types").
 		Parse(tpl.Types).
 		Execute(map[string]interface{}{
-			"withCache": withCache,
-			"table":     table.Name.ToCamel(),
-			"fields":    fieldsString,
+			"withCache":  withCache,
+			"table":      table.Name.ToCamel(),
+			"fields":     fieldsString,
+			"softDelete": table.SoftDelete != nil,
 		})
 	if err != nil {
 		return "", err
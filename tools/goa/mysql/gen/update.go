@@ -8,16 +8,23 @@ import (
 )
 
 func genUpdate(table Table, withCache bool) (string, error) {
+	version, withVersion := table.VersionField()
 	values := make([]string, 0)
 	for _, field := range table.Fields {
 		upperField := field.Name.ToCamel()
 		if upperField == "CreatedAt" || upperField == "UpdatedAt" || field.IsPrimaryKey {
 			continue
 		}
+		if withVersion && field.Name.Source() == version.Name.Source() {
+			continue
+		}
 		values = append(values, "data."+upperField)
 	}
 
 	values = append(values, "data."+table.PrimaryKey.Name.ToCamel())
+	if withVersion {
+		values = append(values, "data."+version.Name.ToCamel())
+	}
 	upperTable := table.Name.ToCamel()
 	output, err := util.With("update").
 		Parse(tpl.Update).
@@ -29,9 +36,11 @@ func genUpdate(table Table, withCache bool) (string, error) {
 			"lowerTable":         stringx.From(upperTable).UnTitle(),
 			"originalPrimaryKey": table.PrimaryKey.Name.Source(),
 			"values":             strings.Join(values, ", "),
+			"withVersion":        withVersion,
+			"version":            version.Name.Source(),
 		})
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 	return output.String(), nil
 }
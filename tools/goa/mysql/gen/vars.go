@@ -13,6 +13,10 @@ func genVars(table Table, withCache bool) (string, error) {
 		keys = append(keys, key.Pattern)
 	}
 	camel := table.Name.ToCamel()
+	var version string
+	if field, ok := table.VersionField(); ok {
+		version = field.Name.Source()
+	}
 	output, err := util.With("var").
 		Parse(tpl.Vars).
 		GoFmt(true).
@@ -23,6 +27,7 @@ func genVars(table Table, withCache bool) (string, error) {
 			"autoIncrement": table.PrimaryKey.AutoIncrement,
 			"primaryKey":    table.PrimaryKey.Name.Source(),
 			"withCache":     withCache,
+			"version":       version,
 		})
 	if err != nil {
 		return "", err
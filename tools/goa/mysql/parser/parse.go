@@ -16,7 +16,12 @@ const (
 	spatial
 )
 
-const timeImport = "time.Time"
+const (
+	timeImport = "time.Time"
+
+	// 乐观锁版本字段名
+	versionField = "version"
+)
 
 var (
 	unSupportDDL        = errors.New("存在不支持的数据库字段类型")
@@ -158,3 +163,13 @@ func (t *Table) ContainsTime() bool {
 	}
 	return false
 }
+
+// VersionField 乐观锁版本字段：名为 version 的整型字段
+func (t *Table) VersionField() (Field, bool) {
+	for _, item := range t.Fields {
+		if item.Name.Source() == versionField && item.DataType == "int64" && !item.IsPrimaryKey {
+			return item, true
+		}
+	}
+	return Field{}, false
+}
@@ -1,7 +1,8 @@
 package tpl
 
 var Delete = `
-func (m *{{.upperStartCamelObject}}Model) Delete({{.lowerStartCamelPrimaryKey}} {{.dataType}}) error {
+{{if .softDelete}}// Delete 软删除，仅标记删除列
+{{end}}func (m *{{.upperStartCamelObject}}Model) Delete({{.lowerStartCamelPrimaryKey}} {{.dataType}}) error {
 	{{if .withCache}}{{if .containsIndexCache}}data, err:=m.FindOne({{.lowerStartCamelPrimaryKey}})
 	if err!=nil{
 		return err
@@ -9,10 +10,10 @@ func (m *{{.upperStartCamelObject}}Model) Delete({{.lowerStartCamelPrimaryKey}}
 
 	{{.keys}}
     _, err {{if .containsIndexCache}}={{else}}:={{end}} m.Exec(func(conn sqlx.Conn) (result sql.Result, err error) {
-		query := ` + "`" + `delete from ` + "` +" + ` m.table + ` + " `" + ` where {{.originalPrimaryKey}} = ?` + "`" + `
-		return conn.Exec(query, {{.lowerStartCamelPrimaryKey}})
-	}, {{.keyValues}}){{else}}query := ` + "`" + `delete from ` + "` +" + ` m.table + ` + " `" + ` where {{.originalPrimaryKey}} = ?` + "`" + `
-		_,err:=m.conn.Exec(query, {{.lowerStartCamelPrimaryKey}}){{end}}
+		query := {{if .softDelete}}` + "`" + `update ` + "` +" + ` m.table + ` + " `" + ` set {{.deleteAssignment}} where {{.originalPrimaryKey}} = ?` + "`" + `{{else}}` + "`" + `delete from ` + "` +" + ` m.table + ` + " `" + ` where {{.originalPrimaryKey}} = ?` + "`" + `{{end}}
+		return conn.Exec(query, {{.deleteArgs}}{{.lowerStartCamelPrimaryKey}})
+	}, {{.keyValues}}){{else}}query := {{if .softDelete}}` + "`" + `update ` + "` +" + ` m.table + ` + " `" + ` set {{.deleteAssignment}} where {{.originalPrimaryKey}} = ?` + "`" + `{{else}}` + "`" + `delete from ` + "` +" + ` m.table + ` + " `" + ` where {{.originalPrimaryKey}} = ?` + "`" + `{{end}}
+		_,err:=m.conn.Exec(query, {{.deleteArgs}}{{.lowerStartCamelPrimaryKey}}){{end}}
 	return err
 }
 `
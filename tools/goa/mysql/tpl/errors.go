@@ -4,5 +4,8 @@ var Error = `package model
 
 import "github.com/z-sdk/goa/lib/store/sqlx"
 
-var ErrNotFound = sqlx.ErrNotFound
+var (
+	ErrNotFound        = sqlx.ErrNotFound
+	ErrVersionConflict = sqlx.ErrVersionConflict
+)
 `
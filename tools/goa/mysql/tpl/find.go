@@ -3,10 +3,14 @@ package tpl
 // 通过id查询
 var FindOne = `
 func (m *{{.upperTable}}Model) FindOne({{.primaryKey}} {{.dataType}}) (*{{.upperTable}}, error) {
-	{{if .withCache}}{{.cacheKeyExpression}}
+	{{if .softDelete}}if m.withTrashed {
+		return m.findWithTrashed("{{.originalPrimaryKey}}", {{.primaryKey}})
+	}
+
+	{{end}}{{if .withCache}}{{.cacheKeyExpression}}
 	var dest {{.upperTable}}
 	err := m.Query(&dest, {{.cacheKeyName}}, func(conn sqlx.Conn, v interface{}) error {
-		query := ` + "`" + `select ` + "`" + ` + {{.lowerTable}}Fields + ` + "`" + ` from ` + "` + " + `m.table ` + " + `" + ` where {{.originalPrimaryKey}} = ? limit 1` + "`" + `
+		query := ` + "`" + `select ` + "`" + ` + {{.lowerTable}}Fields + ` + "`" + ` from ` + "` + " + `m.table ` + " + `" + ` where {{.originalPrimaryKey}} = ?{{.alive}} limit 1` + "`" + `
 		return conn.Query(v, query, {{.primaryKey}})
 	})
 	if err == nil {
@@ -15,7 +19,7 @@ func (m *{{.upperTable}}Model) FindOne({{.primaryKey}} {{.dataType}}) (*{{.upper
 		return nil, ErrNotFound
 	} else {
 		return nil, err
-	}{{else}}query := ` + "`" + `select ` + "`" + ` + {{.lowerTable}}Fields + ` + "`" + ` from ` + "` + " + `m.table ` + " + `" + ` where {{.originalPrimaryKey}} = ? limit 1` + "`" + `
+	}{{else}}query := ` + "`" + `select ` + "`" + ` + {{.lowerTable}}Fields + ` + "`" + ` from ` + "` + " + `m.table ` + " + `" + ` where {{.originalPrimaryKey}} = ?{{.alive}} limit 1` + "`" + `
 	var dest {{.upperTable}}
 	err := m.conn.Query(&dest, query, {{.primaryKey}})
 	if err == nil {
@@ -31,21 +35,25 @@ func (m *{{.upperTable}}Model) FindOne({{.primaryKey}} {{.dataType}}) (*{{.upper
 // 通过指定字段查询
 var FindOneByField = `
 func (m *{{.upperTable}}Model) FindOneBy{{.upperField}}({{.in}}) (*{{.upperTable}}, error) {
-	{{if .withCache}}{{.cacheKeyExpression}}
+	{{if .softDelete}}if m.withTrashed {
+		return m.findWithTrashed("{{.originalField}}", {{.lowerField}})
+	}
+
+	{{end}}{{if .withCache}}{{.cacheKeyExpression}}
 	var dest {{.upperTable}}
 	err := m.QueryIndex(&dest, {{.cacheKeyName}}, func(primary interface{}) string {
 		// 主键的缓存键
 		return fmt.Sprintf("%s%v", {{.primaryKeyLeft}}, primary)
 	}, func(conn sqlx.Conn, v interface{}) (i interface{}, e error) {
 		// 无索引建——主键对应缓存，通过索引键查目标行
-		query := ` + "`" + `select ` + "`" + ` + {{.lowerTable}}Fields + ` + "`" + ` from ` + "` + " + `m.table ` + " + `" + ` where {{.originalField}} = ? limit 1` + "`" + `
+		query := ` + "`" + `select ` + "`" + ` + {{.lowerTable}}Fields + ` + "`" + ` from ` + "` + " + `m.table ` + " + `" + ` where {{.originalField}} = ?{{.alive}} limit 1` + "`" + `
 		if err := conn.Query(&dest, query, {{.lowerField}}); err != nil {
 			return nil, err
 		}
 		return dest.{{.upperStartCamelPrimaryKey}}, nil
 	}, func(conn sqlx.Conn, v, primary interface{}) error {
 		// 如果有索引建——主键对应缓存，则通过主键直接查目标航
-		query := ` + "`" + `select ` + "`" + ` + {{.lowerTable}}Fields + ` + "`" + ` from ` + "` + " + `m.table ` + " + `" + ` where {{.originalPrimaryField}} = ? limit 1` + "`" + `
+		query := ` + "`" + `select ` + "`" + ` + {{.lowerTable}}Fields + ` + "`" + ` from ` + "` + " + `m.table ` + " + `" + ` where {{.originalPrimaryField}} = ?{{.alive}} limit 1` + "`" + `
 		return conn.Query(v, query, primary)
 	})
 	if err == nil {
@@ -56,7 +64,7 @@ func (m *{{.upperTable}}Model) FindOneBy{{.upperField}}({{.in}}) (*{{.upperTable
 		return nil, err
 	}
 }{{else}}var dest {{.upperTable}}
-	query := ` + "`" + `select ` + "`" + ` + {{.lowerTable}}Fields + ` + "`" + ` from ` + "` + " + `m.table ` + " + `" + ` where {{.originalField}} = ? limit 1` + "`" + `
+	query := ` + "`" + `select ` + "`" + ` + {{.lowerTable}}Fields + ` + "`" + ` from ` + "` + " + `m.table ` + " + `" + ` where {{.originalField}} = ?{{.alive}} limit 1` + "`" + `
 	err := m.conn.Query(&dest, query, {{.lowerField}})
 	if err == nil {
 		return &dest, nil
@@ -67,3 +75,20 @@ func (m *{{.upperTable}}Model) FindOneBy{{.upperField}}({{.in}}) (*{{.upperTable
 	}
 }{{end}}
 `
+
+// 按字段查询，包含已软删除的行
+var FindWithTrashed = `
+// findWithTrashed 按字段查询，包含已软删除的行，不经过缓存
+func (m *{{.upperTable}}Model) findWithTrashed(field string, value interface{}) (*{{.upperTable}}, error) {
+	query := ` + "`" + `select ` + "`" + ` + {{.lowerTable}}Fields + ` + "`" + ` from ` + "`" + ` + m.table + ` + "`" + ` where ` + "`" + ` + field + ` + "`" + ` = ? limit 1` + "`" + `
+	var dest {{.upperTable}}
+	err := {{if .withCache}}m.QueryNoCache{{else}}m.conn.Query{{end}}(&dest, query, value)
+	if err == nil {
+		return &dest, nil
+	} else if err == sqlx.ErrNotFound {
+		return nil, ErrNotFound
+	} else {
+		return nil, err
+	}
+}
+`
@@ -16,7 +16,6 @@ var (
 
 	ImportsNoCache = `import (
 	"database/sql"
-	"fmt"
 	"strings"
 	{{if .time}}"time"{{end}}
 
@@ -7,4 +7,11 @@ func New{{.table}}Model(conn sqlx.Conn,{{if .withCache}} clusterConf cache.Clust
 		table: table,
 	}
 }
-`
+{{if .softDelete}}
+// WithTrashed 返回查询时包含已软删除行的模型
+func (m *{{.table}}Model) WithTrashed() *{{.table}}Model {
+	trashed := *m
+	trashed.withTrashed = true
+	return &trashed
+}
+{{end}}`
@@ -4,7 +4,8 @@ var Types = `
 type (
 	{{.table}}Model struct {
 		{{if .withCache}}sqlx.CachedConn{{else}}conn sqlx.Conn{{end}}
-		table string
+		table string{{if .softDelete}}
+		withTrashed bool // 查询时是否包含已软删除的行{{end}}
 	}
 
 	{{.table}} struct {
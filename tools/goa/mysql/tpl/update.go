@@ -1,13 +1,14 @@
 package tpl
 
 var Update = `
-func (m *{{.upperTable}}Model) Update(data {{.upperTable}}) error {
+{{if .withVersion}}// Update 按版本号更新并递增版本号，版本号不一致时返回 ErrVersionConflict
+{{end}}func (m *{{.upperTable}}Model) Update(data {{.upperTable}}) error {
 	{{if .withCache}}{{.primaryCacheKey}}
 	_, err := m.Exec(func(conn sqlx.Conn) (result sql.Result, err error) {
-		query := ` + "`" + `update ` + "` +" + ` m.table +` + "` " + `set ` + "` + " + `{{.lowerTable}}FieldsWithPlaceHolder` + " + `" + ` where {{.originalPrimaryKey}} = ?` + "`" + `
-		return conn.Exec(query, {{.values}})
-	}, {{.primaryKeyName}}){{else}}query := ` + "`" + `update ` + "` +" + `m.table +` + "` " + `set ` + "` +" + `{{.lowerTable}}FieldsWithPlaceHolder` + " + `" + ` where {{.originalPrimaryKey}} = ?` + "`" + `
-	_,err := m.conn.Exec(query, {{.values}}){{end}}
+		query := ` + "`" + `update ` + "` +" + ` m.table +` + "` " + `set ` + "` + " + `{{.lowerTable}}FieldsWithPlaceHolder` + " + `" + `{{if .withVersion}}, {{.version}} = {{.version}} + 1{{end}} where {{.originalPrimaryKey}} = ?{{if .withVersion}} and {{.version}} = ?{{end}}` + "`" + `
+		return {{if .withVersion}}sqlx.ExecWithVersion(conn, {{else}}conn.Exec({{end}}query, {{.values}})
+	}, {{.primaryKeyName}}){{else}}query := ` + "`" + `update ` + "` +" + `m.table +` + "` " + `set ` + "` +" + `{{.lowerTable}}FieldsWithPlaceHolder` + " + `" + `{{if .withVersion}}, {{.version}} = {{.version}} + 1{{end}} where {{.originalPrimaryKey}} = ?{{if .withVersion}} and {{.version}} = ?{{end}}` + "`" + `
+	_,err := {{if .withVersion}}sqlx.ExecWithVersion(m.conn, {{else}}m.conn.Exec({{end}}query, {{.values}}){{end}}
 	return err
 }
 `